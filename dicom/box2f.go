@@ -13,8 +13,12 @@ type Box2f struct {
 }
 
 func AABB2f(corners []*math32.Vector2) Box2f {
+	if len(corners) == 0 {
+		min, max := math32.NewVec2(), math32.NewVec2()
+		return Box2f{Min: min, Max: max, Box: math32.NewBox2(min, max)}
+	}
 	minx, miny := float32(math.MaxFloat32), float32(math.MaxFloat32)
-	maxx, maxy := float32(math.SmallestNonzeroFloat32), float32(math.SmallestNonzeroFloat32)
+	maxx, maxy := float32(-math.MaxFloat32), float32(-math.MaxFloat32)
 	for _, corner := range corners {
 
 		cx := corner.X
@@ -0,0 +1,252 @@
+package volume
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/g3n/engine/math32"
+)
+
+// CineMode is how a cine moves its plane from frame to frame.
+type CineMode int
+
+const (
+	// Scroll steps through the volume's own slices along Axis.
+	Scroll CineMode = iota
+	// Rotate turns a plane containing Axis through the volume centre around
+	// it.
+	Rotate
+	// Slab moves a plane perpendicular to Axis through the volume, combining
+	// Thickness mm around it.
+	Slab
+)
+
+var cineModeNames = []string{"scroll", "rotate", "slab"}
+
+func (mode CineMode) String() string {
+	return enumName(cineModeNames, "CineMode", int(mode))
+}
+
+// ParseCineMode reads a mode written as by String.
+func ParseCineMode(s string) (CineMode, error) {
+	for i, name := range cineModeNames {
+		if strings.EqualFold(name, s) {
+			return CineMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown cine mode %q", s)
+}
+
+// Cine describes a sweep of a plane through a volume. Axis is 0, 1 or 2 for
+// the volume's column, row and slice axes. Range and Step are in slice
+// indices for Scroll, degrees for Rotate and mm from the volume centre for
+// Slab; a zero Range covers the whole volume, or a full turn, and a zero Step
+// moves one slice, degree or voxel at a time. A Scroll range must lie within
+// the volume's slices. A zero WindowWidth keeps the volume's window.
+//
+// With a Projection, a rotating frame projects the whole volume seen from
+// the plane's side instead of cutting it, and a slab combines its thickness
+// that way rather than averaging. Size is the width in pixels of rotating and
+// slab frames, 256 when zero.
+type Cine struct {
+	Mode         CineMode
+	Axis         int
+	Range        [2]float32
+	Step         float32
+	Thickness    float32
+	Projection   Projection
+	Size         int
+	WindowCenter float32
+	WindowWidth  float32
+}
+
+// span returns the range and step of c filled in with the defaults for v.
+func (c Cine) span(v Volume) (float32, float32, float32) {
+	from, to, step := c.Range[0], c.Range[1], c.Step
+	switch c.Mode {
+	case Scroll:
+		if from == 0 && to == 0 {
+			to = float32(dims(v)[c.Axis] - 1)
+		}
+		if step == 0 {
+			step = 1
+		}
+	case Rotate:
+		if from == 0 && to == 0 {
+			to = 360
+		}
+		if step == 0 {
+			step = 1
+		}
+	case Slab:
+		size := v.DcmData.VoxelSize.Component(c.Axis)
+		if from == 0 && to == 0 {
+			half := float32(dims(v)[c.Axis]) * size / 2
+			from, to = -half, half
+		}
+		if step == 0 {
+			step = size
+		}
+	}
+	if (to < from) != (step < 0) {
+		step = -step
+	}
+	return from, to, step
+}
+
+func dims(v Volume) [3]int {
+	return [3]int{v.DcmData.Cols, v.DcmData.Rows, v.DcmData.Depth}
+}
+
+// Frames cuts every frame of the sweep. Rotating and slab frames share one
+// square image covering the whole volume, so the picture stays put while
+// the plane moves.
+func (c Cine) Frames(v Volume) ([]*image.RGBA, error) {
+	if c.Axis < 0 || c.Axis > 2 {
+		return nil, fmt.Errorf("cine axis %d is not 0, 1 or 2", c.Axis)
+	}
+	if c.WindowWidth > 0 {
+		data := v.DcmData
+		data.Window, data.Level = c.WindowCenter, c.WindowWidth
+		windowed := FromValues(data, v.Values)
+		windowed.Dicoms = v.Dicoms
+		v = windowed
+	}
+	from, to, step := c.span(v)
+	if step == 0 {
+		return nil, fmt.Errorf("cine step is zero")
+	}
+	if last := float32(dims(v)[c.Axis] - 1); c.Mode == Scroll && (math32.Min(from, to) < 0 || math32.Max(from, to) > last) {
+		return nil, fmt.Errorf("cine range %v..%v is outside slices 0..%v", from, to, last)
+	}
+	count := int(math32.Floor((to-from)/step+1e-4)) + 1
+	if c.Mode == Rotate && math32.Abs(to-from) >= 360 {
+		// The last frame would repeat the first.
+		count--
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("cine range %v..%v holds no frames", from, to)
+	}
+
+	dirs := [3]*math32.Vector3{}
+	dirs[0], dirs[1], dirs[2] = axes(v)
+	axis := dirs[c.Axis]
+	center := v.GetCorners().Box.Center(nil)
+	diagonal := v.GetCorners().Box.Size(nil).Length()
+	square := Box2f{Min: math32.NewVector2(-diagonal/2, -diagonal/2), Max: math32.NewVector2(diagonal/2, diagonal/2)}
+	size := c.Size
+	if size <= 0 {
+		size = 256
+	}
+	pixelSize := diagonal / float32(size)
+
+	// The rotating plane starts on the next volume axis round from Axis and
+	// keeps Axis running down the image.
+	start := dirs[(c.Axis+1)%3]
+	down := math32.NewVec3().Copy(axis).Negate()
+
+	frames := make([]*image.RGBA, 0, count)
+	for i := 0; i < count; i++ {
+		at := from + float32(i)*step
+		var img *image.RGBA
+		switch c.Mode {
+		case Scroll:
+			slice := int(math32.Round(at))
+			frame := [3]func(Volume, int) SliceFrame{Sagittal, Coronal, Axial}[c.Axis](v, slice)
+			frame.Cut(v)
+			img = *frame.Mpr
+		case Rotate:
+			angle := at * math32.Pi / 180
+			normal := math32.NewVec3().Copy(start).ApplyAxisAngle(axis, angle)
+			x := math32.NewVec3().CrossVectors(down, normal).Normalize()
+			basis := math32.NewMatrix4().MakeBasis(x, down, normal)
+			frame := MakeSliceFrame(center, basis, v).Region(square, pixelSize)
+			if c.Projection != NoProjection {
+				frame.Project(v, c.Projection, -diagonal/2, diagonal/2)
+			} else {
+				frame.Cut(v)
+			}
+			img = *frame.Mpr
+		case Slab:
+			point := math32.NewVec3().Copy(axis).MultiplyScalar(at).Add(center)
+			frame := PointNormal(v, point, axis).Region(square, pixelSize)
+			projection := c.Projection
+			if projection == NoProjection {
+				projection = MeanIntensity
+			}
+			frame.Project(v, projection, -c.Thickness/2, c.Thickness/2)
+			img = *frame.Mpr
+		}
+		frames = append(frames, img)
+	}
+	return frames, nil
+}
+
+// WriteCine writes frames as an animated GIF playing at fps frames a second
+// when path ends in .gif, or else as numbered PNGs in the directory path. It
+// returns the files written.
+func WriteCine(path string, frames []*image.RGBA, fps float32) ([]string, error) {
+	if strings.EqualFold(filepath.Ext(path), ".gif") {
+		return []string{path}, writeGIF(path, frames, fps)
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	var written []string
+	for i, img := range frames {
+		name := filepath.Join(path, fmt.Sprintf("frame-%04d.png", i))
+		if err := WritePNG(name, img); err != nil {
+			return written, err
+		}
+		written = append(written, name)
+	}
+	return written, nil
+}
+
+func writeGIF(path string, frames []*image.RGBA, fps float32) error {
+	if fps <= 0 {
+		return fmt.Errorf("cine frame rate %v", fps)
+	}
+	gray := make(color.Palette, 256)
+	for i := range gray {
+		gray[i] = color.Gray{Y: uint8(i)}
+	}
+	delay := int(math.Round(100 / float64(fps)))
+	anim := &gif.GIF{}
+	for _, img := range frames {
+		frame := image.NewPaletted(img.Bounds(), gray)
+		draw.Draw(frame, frame.Rect, img, img.Bounds().Min, draw.Src)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = gif.EncodeAll(f, anim)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// WritePNG saves img to path as a PNG.
+func WritePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"awesomeProject/phantom"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCine(t *testing.T) {
+	p := phantom.New(24, 20, 12, 2)
+	p.Shapes = []phantom.Shape{phantom.Sphere{Center: p.VoxelCenter(12, 10, 6), Radius: 10, Value: 1000}}
+	v := p.Volume()
+	dir := t.TempDir()
+
+	scroll, err := volume.Cine{Mode: volume.Scroll, Axis: 2}.Frames(v)
+	if err != nil || len(scroll) != 12 {
+		t.Fatalf("scroll gave %d frames, %v", len(scroll), err)
+	}
+	if _, err := (volume.Cine{Mode: volume.Scroll, Axis: 2, Range: [2]float32{0, 20}}).Frames(v); err == nil {
+		t.Error("scrolled past the last slice")
+	}
+	if _, err := (volume.Cine{Mode: volume.Scroll, Axis: 2, Range: [2]float32{-1, 5}}).Frames(v); err == nil {
+		t.Error("scrolled before the first slice")
+	}
+	rotate, err := volume.Cine{Mode: volume.Rotate, Axis: 2, Step: 30}.Frames(v)
+	if err != nil || len(rotate) != 12 {
+		t.Fatalf("rotate gave %d frames, %v", len(rotate), err)
+	}
+	for _, img := range rotate[1:] {
+		if img.Bounds() != rotate[0].Bounds() {
+			t.Errorf("rotating frame %v, first %v", img.Bounds(), rotate[0].Bounds())
+		}
+	}
+	slab, err := volume.Cine{Mode: volume.Slab, Axis: 0, Range: [2]float32{-4, 4}, Step: 4, Thickness: 6}.Frames(v)
+	if err != nil || len(slab) != 3 {
+		t.Fatalf("slab gave %d frames, %v", len(slab), err)
+	}
+	// The sphere shows brighter in the middle of the centre slab than at its corner.
+	b := slab[1].Bounds()
+	mid := slab[1].RGBAAt(b.Dx()/2, b.Dy()/2).R
+	if edge := slab[1].RGBAAt(0, 0).R; mid <= edge {
+		t.Errorf("slab centre %d, corner %d", mid, edge)
+	}
+
+	path := filepath.Join(dir, "cine.gif")
+	if _, err := volume.WriteCine(path, rotate, 10); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil || len(anim.Image) != len(rotate) || anim.Delay[0] != 10 {
+		t.Errorf("gif: %v", err)
+	}
+
+	written, err := volume.WriteCine(filepath.Join(dir, "frames"), slab, 10)
+	if err != nil || len(written) != len(slab) {
+		t.Errorf("png sequence %v: %v", written, err)
+	}
+}
+
+func TestCineModeNames(t *testing.T) {
+	for _, mode := range []volume.CineMode{volume.Scroll, volume.Rotate, volume.Slab} {
+		if got, err := volume.ParseCineMode(mode.String()); err != nil || got != mode {
+			t.Errorf("%v read back as %v, %v", mode, got, err)
+		}
+	}
+	if name := volume.CineMode(9).String(); name != "CineMode(9)" {
+		t.Errorf("invalid mode named %q", name)
+	}
+}
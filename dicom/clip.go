@@ -0,0 +1,106 @@
+package volume
+
+import "github.com/g3n/engine/math32"
+
+// Clip limits what the 3D views show to the crop Box, in voxel coordinates
+// and nil for the whole volume, and to the front of each of Planes, in
+// patient coordinates: the side their normals point to.
+type Clip struct {
+	Box    *math32.Box3
+	Planes []math32.Plane
+}
+
+// VoxelPlanes returns c's planes in v's voxel coordinates. Their normals are
+// not of unit length, so they tell the sides apart but not distances.
+func (c Clip) VoxelPlanes(v Volume) []math32.Plane {
+	// A point q in voxels lies at L q + b in the patient's, so n·p + d
+	// becomes (Lᵀn)·q + n·b + d.
+	e := v.DcmData.Calibration
+	planes := make([]math32.Plane, len(c.Planes))
+	for i := range c.Planes {
+		n, d := planeNormal(&c.Planes[i]), planeConstant(&c.Planes[i])
+		normal := math32.NewVector3(
+			e[0]*n.X+e[1]*n.Y+e[2]*n.Z,
+			e[4]*n.X+e[5]*n.Y+e[6]*n.Z,
+			e[8]*n.X+e[9]*n.Y+e[10]*n.Z)
+		planes[i].Set(normal, n.X*e[12]+n.Y*e[13]+n.Z*e[14]+d)
+	}
+	return planes
+}
+
+// Segment clips the ray start + t·dir from t0 to t1, in voxel coordinates,
+// to the box and to planes, c's planes as given by VoxelPlanes. ok is false
+// when nothing is left of it.
+func (c Clip) Segment(planes []math32.Plane, start *math32.Vector3, dir *math32.Vector3, t0 float32, t1 float32) (float32, float32, bool) {
+	if c.Box != nil {
+		for i := 0; i < 3; i++ {
+			o, d := start.Component(i), dir.Component(i)
+			lo, hi := c.Box.Min.Component(i), c.Box.Max.Component(i)
+			if math32.Abs(d) < 1e-9 {
+				if o < lo || o > hi {
+					return 0, 0, false
+				}
+				continue
+			}
+			a, b := (lo-o)/d, (hi-o)/d
+			if a > b {
+				a, b = b, a
+			}
+			t0, t1 = math32.Max(t0, a), math32.Min(t1, b)
+		}
+	}
+	for i := range planes {
+		n := planeNormal(&planes[i])
+		at := planes[i].DistanceToPoint(start)
+		along := n.Dot(dir)
+		switch {
+		case math32.Abs(along) < 1e-9:
+			if at < 0 {
+				return 0, 0, false
+			}
+		case along > 0:
+			t0 = math32.Max(t0, -at/along)
+		default:
+			t1 = math32.Min(t1, -at/along)
+		}
+	}
+	return t0, t1, t0 <= t1
+}
+
+// keeps reports whether patient point p is shown, given the voxel
+// coordinates toVoxel and the planes in them from VoxelPlanes.
+func (c Clip) keeps(toVoxel *math32.Matrix4, planes []math32.Plane, p math32.Vector3) bool {
+	p.ApplyMatrix4(toVoxel)
+	if c.Box != nil && !c.Box.ContainsPoint(&p) {
+		return false
+	}
+	for i := range planes {
+		if planes[i].DistanceToPoint(&p) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Clip returns the triangles of the mesh whose corners c all keeps, the
+// surface of v.
+func (mesh Mesh) Clip(v Volume, c Clip) Mesh {
+	if c.Box == nil && len(c.Planes) == 0 {
+		return mesh
+	}
+	toVoxel := math32.NewMatrix4()
+	toVoxel.GetInverse(v.DcmData.Calibration)
+	planes := c.VoxelPlanes(v)
+	kept := make([]bool, len(mesh.Vertices))
+	for i, p := range mesh.Vertices {
+		kept[i] = c.keeps(toVoxel, planes, p)
+	}
+	out := Mesh{Vertices: mesh.Vertices}
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		tri := mesh.Indices[i : i+3]
+		if kept[tri[0]] && kept[tri[1]] && kept[tri[2]] {
+			out.Indices = append(out.Indices, tri...)
+		}
+	}
+	return out.compact()
+}
@@ -0,0 +1,45 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"awesomeProject/phantom"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestClip(t *testing.T) {
+	p := phantom.New(40, 40, 40, 1)
+	center := p.VoxelCenter(20, 20, 20)
+	p.Shapes = []phantom.Shape{phantom.Sphere{Center: center, Radius: 12, Value: 1000}}
+	v := p.Volume()
+	mesh := volume.Isosurface(v, 0)
+
+	// A plane through the centre keeps the half its normal points to.
+	normal := math32.NewVector3(0, 0, 1)
+	half := volume.Clip{Planes: []math32.Plane{*math32.NewPlane(normal, -normal.Dot(center))}}
+	clipped := mesh.Clip(v, half)
+	if n, all := len(clipped.Indices), len(mesh.Indices); n < all*4/10 || n > all*6/10 {
+		t.Errorf("half the sphere kept %d of %d indices", n, all)
+	}
+	for _, vertex := range clipped.Vertices {
+		if vertex.Z < center.Z {
+			t.Fatalf("vertex %v behind the plane", vertex)
+		}
+	}
+
+	// The same cut as a crop box, in voxels.
+	box := math32.NewBox3(math32.NewVector3(0, 0, 20), math32.NewVector3(39, 39, 39))
+	cropped := mesh.Clip(v, volume.Clip{Box: box})
+	if len(cropped.Indices) != len(clipped.Indices) {
+		t.Errorf("crop box kept %d indices, plane %d", len(cropped.Indices), len(clipped.Indices))
+	}
+
+	// A ray along z through both.
+	both := volume.Clip{Box: math32.NewBox3(math32.NewVector3(0, 0, 0), math32.NewVector3(39, 39, 30)), Planes: half.Planes}
+	start, dir := math32.NewVector3(20, 20, -5), math32.NewVector3(0, 0, 1)
+	t0, t1, ok := both.Segment(both.VoxelPlanes(v), start, dir, 0, 50)
+	if !ok || math32.Abs(t0-25) > 1e-3 || math32.Abs(t1-35) > 1e-3 {
+		t.Errorf("segment %v to %v, %v; want 25 to 35", t0, t1, ok)
+	}
+}
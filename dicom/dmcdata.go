@@ -1,6 +1,7 @@
 package volume
 
 import (
+	"fmt"
 	"math"
 	"strconv"
 
@@ -21,6 +22,11 @@ type DcmData struct {
 	Orientation *math32.Matrix4
 	Origin      *math32.Vector3
 	VoxelSize   *math32.Vector3
+	// LUT colours the windowed images, gray when nil.
+	LUT *LUT
+	// Units are those of the rescaled values: HU for CT, BQML or an SUV
+	// mode for PET, empty when unknown.
+	Units string
 }
 
 func readPixelData(dcm dicom.Dataset, tag tag.Tag) (dicom.PixelDataInfo, error) {
@@ -94,24 +100,62 @@ func readDcmData(dcm []DicomFile) DcmData {
 	slope, _ := readTag(dataset, tag.RescaleSlope)
 	orientation, _, _ := readCal(dataset, tag.ImageOrientationPatient)
 	intercept, _ := readTag(dataset, tag.RescaleIntercept)
-	origin := math32.NewVec3()
+	position, _ := readOrigin(dataset, tag.ImagePositionPatient)
+	origin := math32.NewVec3().Copy(&position)
 	z := math32.NewVector3(0, 0, 1)
 	z.ApplyMatrix4(orientation)
 	z.Normalize()
 	voxelSize, _ := readVoxelSize(dataset, dcm[1].dataset, tag.PixelSpacing, z)
+	data := NewDcmData(rows, cols, len(dcm), orientation, origin, voxelSize)
+	data.Window = window
+	data.Level = level
+	data.Slope = slope
+	data.Intercept = intercept
+	switch stringOf(dataset, tag.Modality) {
+	case "CT":
+		data.Units = "HU"
+	case "PT":
+		data.Units = stringOf(dataset, tag.Units)
+	}
+	return data
+}
+
+// NewDcmData builds the geometry of a rows x cols x depth volume whose voxel
+// axes follow orientation, scaled by voxelSize and placed at origin.
+func NewDcmData(rows int, cols int, depth int, orientation *math32.Matrix4, origin *math32.Vector3, voxelSize *math32.Vector3) DcmData {
 	cal := math32.NewMatrix4().Multiply(orientation).Scale(voxelSize).SetPosition(math32.NewVec3().Copy(origin))
 	ori := math32.NewMatrix4().Multiply(orientation)
-	return DcmData{rows,
-		cols,
-		len(dcm),
-		window,
-		level,
-		slope,
-		intercept,
-		cal,
-		ori,
-		origin,
-		voxelSize}
+	return DcmData{
+		Rows:        rows,
+		Cols:        cols,
+		Depth:       depth,
+		Slope:       1,
+		Calibration: cal,
+		Orientation: ori,
+		Origin:      origin,
+		VoxelSize:   voxelSize,
+	}
+}
+
+// FormatValue writes a rescaled value with its units.
+func (data DcmData) FormatValue(value float32) string {
+	switch data.Units {
+	case "":
+		return fmt.Sprintf("%.0f", value)
+	case "HU", "BQML":
+		return fmt.Sprintf("%.0f %s", value, data.Units)
+	}
+	return fmt.Sprintf("%.2f %s", value, data.Units)
+}
+
+// ToByte maps a rescaled pixel value to 0..255 through the window.
+func (data DcmData) ToByte(pixel float32) byte {
+	if pixel <= data.Window-0.5-(data.Level-1)/2 {
+		return 0
+	} else if pixel > data.Window-0.5+(data.Level-1)/2 {
+		return 255
+	}
+	return uint8((((pixel)-((data.Window)-0.5))/(data.Level-1) + 0.5) * (255))
 }
 
 func readVoxelSize(dcm dicom.Dataset, dcm2 dicom.Dataset, tg tag.Tag, dirZ *math32.Vector3) (*math32.Vector3, error) {
@@ -0,0 +1,192 @@
+package volume
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"sync"
+
+	"github.com/g3n/engine/math32"
+)
+
+// VolumeRenderer draws a volume by casting a ray through every pixel on the
+// CPU, compositing the transfer function's colours front to back.
+type VolumeRenderer struct {
+	Transfer Transfer
+	// Step is the distance between samples along a ray, in voxels.
+	Step float32
+	// Cutoff is the accumulated opacity at which a ray stops early.
+	Cutoff float32
+	// Shading lights samples by their gradient, as if lit from the eye.
+	Shading bool
+	// Clip crops the volume and cuts it away behind planes.
+	Clip Clip
+}
+
+func NewVolumeRenderer(tf Transfer) *VolumeRenderer {
+	return &VolumeRenderer{Transfer: tf, Step: 1, Cutoff: 0.95, Shading: true}
+}
+
+// Render casts width x height rays through the view whose inverse
+// view-projection matrix is unproject, and returns the image composited
+// over black, top row first.
+func (r *VolumeRenderer) Render(v Volume, unproject math32.Matrix4, width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if len(v.Values) == 0 || width <= 0 || height <= 0 {
+		return img
+	}
+	toVoxel := math32.NewMatrix4()
+	toVoxel.GetInverse(v.DcmData.Calibration)
+	rotation := math32.NewMatrix4().ExtractRotation(v.DcmData.Orientation)
+	planes := r.Clip.VoxelPlanes(v)
+	ray := func(px int, py int) (math32.Vector3, math32.Vector3) {
+		nx := (float32(px)+0.5)/float32(width)*2 - 1
+		ny := 1 - (float32(py)+0.5)/float32(height)*2
+		near := math32.NewVector3(nx, ny, -1).ApplyProjection(&unproject)
+		far := math32.NewVector3(nx, ny, 1).ApplyProjection(&unproject)
+		return *near, *far
+	}
+
+	rows := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tr := castRay{r, NewSampler(v), newTransferTable(r.Transfer), toVoxel, rotation, planes}
+			for py := range rows {
+				for px := 0; px < width; px++ {
+					near, far := ray(px, py)
+					img.SetRGBA(px, py, tr.cast(&near, &far))
+				}
+			}
+		}()
+	}
+	for py := 0; py < height; py++ {
+		rows <- py
+	}
+	close(rows)
+	wg.Wait()
+	return img
+}
+
+// castRay holds what one worker needs to cast rays.
+type castRay struct {
+	r        *VolumeRenderer
+	s        Sampler
+	tf       transferTable
+	toVoxel  *math32.Matrix4
+	rotation *math32.Matrix4
+	planes   []math32.Plane
+}
+
+// cast composites the samples of the ray from near to far, both in patient
+// coordinates.
+func (c castRay) cast(near *math32.Vector3, far *math32.Vector3) color.RGBA {
+	light := math32.NewVec3().SubVectors(far, near).Normalize()
+	start := math32.NewVec3().Copy(near).ApplyMatrix4(c.toVoxel)
+	end := math32.NewVec3().Copy(far).ApplyMatrix4(c.toVoxel)
+	dir := math32.NewVec3().SubVectors(end, start)
+	length := dir.Length()
+	if length == 0 {
+		return color.RGBA{A: 0xFF}
+	}
+	dir.DivideScalar(length)
+
+	// Clip the ray to the voxel centres' box.
+	tMin, tMax := float32(0), length
+	for i := 0; i < 3; i++ {
+		o, d, hi := start.Component(i), dir.Component(i), float32(c.s.Max()[i])
+		if math32.Abs(d) < 1e-9 {
+			if o < 0 || o > hi {
+				return color.RGBA{A: 0xFF}
+			}
+			continue
+		}
+		t0, t1 := (0-o)/d, (hi-o)/d
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		tMin, tMax = math32.Max(tMin, t0), math32.Min(tMax, t1)
+	}
+	tMin, tMax, ok := c.r.Clip.Segment(c.planes, start, dir, tMin, tMax)
+	if !ok {
+		return color.RGBA{A: 0xFF}
+	}
+
+	var acc math32.Color
+	alpha := float32(0)
+	step := c.r.Step
+	for t := tMin; t <= tMax && alpha < c.r.Cutoff; t += step {
+		x, y, z := start.X+dir.X*t, start.Y+dir.Y*t, start.Z+dir.Z*t
+		col, opacity := c.tf.at(c.s.At(x, y, z))
+		if opacity <= 0 {
+			continue
+		}
+		// Opacities are per voxel; correct them for the step taken.
+		if step != 1 {
+			opacity = 1 - math32.Pow(1-opacity, step)
+		}
+		shade := float32(1)
+		if c.r.Shading {
+			g := c.s.Gradient(x, y, z)
+			if g.Length() > 1e-3 {
+				g.ApplyMatrix4(c.rotation).Normalize()
+				shade = 0.3 + 0.7*math32.Abs(g.Dot(light))
+			}
+		}
+		weight := (1 - alpha) * opacity * shade
+		acc.R += col.R * weight
+		acc.G += col.G * weight
+		acc.B += col.B * weight
+		alpha += (1 - alpha) * opacity
+	}
+	toByte := func(f float32) uint8 { return uint8(math32.Clamp(f, 0, 1) * 255) }
+	return color.RGBA{R: toByte(acc.R), G: toByte(acc.G), B: toByte(acc.B), A: 0xFF}
+}
+
+// Transfer gives the colour and opacity a value is rendered with. Span is
+// the range of values over which they change; they hold beyond it.
+type Transfer interface {
+	At(value float32) (math32.Color, float32)
+	Span() (float32, float32)
+}
+
+// transferTable samples a transfer function at evenly spaced values, so the
+// ray caster looks colours up instead of working them out.
+type transferTable struct {
+	min     float32
+	scale   float32
+	colors  []math32.Color
+	opacity []float32
+}
+
+const transferTableSize = 4096
+
+func newTransferTable(tf Transfer) transferTable {
+	t := transferTable{
+		colors:  make([]math32.Color, transferTableSize),
+		opacity: make([]float32, transferTableSize),
+	}
+	low, high := tf.Span()
+	t.min = low
+	span := high - low
+	if span <= 0 {
+		span = 1
+	}
+	t.scale = float32(transferTableSize-1) / span
+	for i := range t.colors {
+		t.colors[i], t.opacity[i] = tf.At(t.min + float32(i)/t.scale)
+	}
+	return t
+}
+
+func (t transferTable) at(value float32) (*math32.Color, float32) {
+	i := int((value - t.min) * t.scale)
+	if i < 0 {
+		i = 0
+	} else if i >= transferTableSize {
+		i = transferTableSize - 1
+	}
+	return &t.colors[i], t.opacity[i]
+}
@@ -0,0 +1,89 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"awesomeProject/phantom"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+// flatTransfer renders every value red with one opacity.
+type flatTransfer float32
+
+func (f flatTransfer) At(value float32) (math32.Color, float32) {
+	return math32.Color{R: 1}, float32(f)
+}
+
+func (f flatTransfer) Span() (float32, float32) {
+	return 0, 1
+}
+
+// renderCube renders a uniform cube of 8 voxels one mm wide a side, at the
+// patient origin, with rays through the voxel centres front to back along
+// z, from 4.5 mm before the first slice to 4.5 mm after the last.
+func renderCube(r *volume.VolumeRenderer) *image.RGBA {
+	p := phantom.New(8, 8, 8, 1)
+	p.Background = 100
+	unproject := *math32.NewMatrix4().Set(
+		4, 0, 0, 3.5,
+		0, 4, 0, 3.5,
+		0, 0, 8, 3.5,
+		0, 0, 0, 1)
+	return r.Render(p.Volume(), unproject, 8, 8)
+}
+
+// covered is the red of samples of opacity composited front to back.
+func covered(opacity float64, samples int) uint8 {
+	return uint8((1 - math.Pow(1-opacity, float64(samples))) * 255)
+}
+
+func nearByte(got uint8, want uint8) bool {
+	return math.Abs(float64(got)-float64(want)) <= 1
+}
+
+func TestVolumeRenderer(t *testing.T) {
+	render := func(opacity float32, cutoff float32) *image.RGBA {
+		r := volume.NewVolumeRenderer(flatTransfer(opacity))
+		r.Cutoff, r.Shading = cutoff, false
+		return renderCube(r)
+	}
+	if c := render(0.1, 1).RGBAAt(4, 4); !nearByte(c.R, covered(0.1, 8)) || c.G != 0 || c.B != 0 {
+		t.Errorf("eight samples composited to %v, want red %d", c, covered(0.1, 8))
+	}
+	// The ray stops once two samples of 0.5 pass the cutoff.
+	if c := render(0.5, 0.7).RGBAAt(4, 4); !nearByte(c.R, covered(0.5, 2)) {
+		t.Errorf("cut off at red %d, want %d", c.R, covered(0.5, 2))
+	}
+	if c := render(0.5, 1).RGBAAt(4, 4); !nearByte(c.R, covered(0.5, 8)) {
+		t.Errorf("without cutoff red %d, want %d", c.R, covered(0.5, 8))
+	}
+
+	// The window ramp is transparent below the window and opaque above it.
+	below := renderCube(volume.NewVolumeRenderer(volume.WindowTransfer(500, 100)))
+	above := renderCube(volume.NewVolumeRenderer(volume.WindowTransfer(-500, 100)))
+	if b, a := below.RGBAAt(4, 4), above.RGBAAt(4, 4); b.R != 0 || a.R < 200 {
+		t.Errorf("below the window red %d, above %d", b.R, a.R)
+	}
+}
+
+func TestVolumeRendererClip(t *testing.T) {
+	render := func(clip volume.Clip) *image.RGBA {
+		r := volume.NewVolumeRenderer(flatTransfer(0.1))
+		r.Shading, r.Clip = false, clip
+		return renderCube(r)
+	}
+	// Keeping the back half leaves four samples on each ray, and cropping
+	// to the first four columns leaves the rest black.
+	back := math32.NewPlane(math32.NewVector3(0, 0, 1), -3.5)
+	if c := render(volume.Clip{Planes: []math32.Plane{*back}}).RGBAAt(4, 4); !nearByte(c.R, covered(0.1, 4)) {
+		t.Errorf("behind the plane red %d, want %d", c.R, covered(0.1, 4))
+	}
+	crop := math32.NewBox3(math32.NewVec3(), math32.NewVector3(3, 7, 7))
+	img := render(volume.Clip{Box: crop})
+	if inside, outside := img.RGBAAt(1, 4), img.RGBAAt(6, 4); !nearByte(inside.R, covered(0.1, 8)) || outside.R != 0 {
+		t.Errorf("cropped: inside red %d, outside %d", inside.R, outside.R)
+	}
+}
@@ -0,0 +1,58 @@
+package volume
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/g3n/engine/math32"
+)
+
+// Fuse blends other, resampled onto the frame's image through patient
+// coordinates, over the reformat Cut or Project made. Other's window and LUT
+// colour it and opacity, from 0 to 1, weighs it; pixels falling outside
+// other keep the reformat.
+func (sliceFrame SliceFrame) Fuse(other Volume, opacity float32) {
+	base := *sliceFrame.Mpr
+	if base == nil || len(other.Values) == 0 {
+		return
+	}
+	bounds := base.Bounds()
+	toVoxel := math32.NewMatrix4()
+	toVoxel.GetInverse(other.DcmData.Calibration)
+	s := NewSampler(other)
+
+	// Walk the image in other's voxel coordinates: a start and fixed steps.
+	origin := sliceFrame.ImageOrigin().ApplyMatrix4(toVoxel)
+	zero := math32.NewVec3().ApplyMatrix4(toVoxel)
+	step := func(axis *math32.Vector3, length float32) *math32.Vector3 {
+		dir := math32.NewVec3().Copy(axis).ApplyMatrix4(sliceFrame.RotatedFrame.Basis).Normalize()
+		return dir.MultiplyScalar(length).ApplyMatrix4(toVoxel).Sub(zero)
+	}
+	across := step(math32.NewVector3(1, 0, 0), sliceFrame.ImagePixelSize.X)
+	down := step(math32.NewVector3(0, 1, 0), sliceFrame.ImagePixelSize.Y)
+
+	opacity = math32.Clamp(opacity, 0, 1)
+	blend := func(a uint8, b uint8) uint8 { return uint8(float32(a)*(1-opacity) + float32(b)*opacity + 0.5) }
+	fused := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := math32.NewVec3().Copy(down).MultiplyScalar(float32(y - bounds.Min.Y)).Add(origin)
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := base.RGBAAt(x, y)
+			p := math32.NewVec3().Copy(across).MultiplyScalar(float32(x - bounds.Min.X)).Add(row)
+			if s.Inside(p.X, p.Y, p.Z) {
+				o := other.DcmData.color(other.DcmData.ToByte(s.At(p.X, p.Y, p.Z)))
+				c = color.RGBA{blend(c.R, o.R), blend(c.G, o.G), blend(c.B, o.B), 0xFF}
+			}
+			fused.SetRGBA(x, y, c)
+		}
+	}
+	*sliceFrame.Mpr = fused
+}
+
+// color is how Mpr shows windowed pixel p.
+func (data DcmData) color(p byte) color.RGBA {
+	if data.LUT != nil {
+		return data.LUT.Colors[p]
+	}
+	return color.RGBA{A: 0xFF, R: p, G: p, B: p}
+}
@@ -0,0 +1,45 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"awesomeProject/phantom"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestFuse(t *testing.T) {
+	ct := phantom.New(40, 40, 40, 1)
+	ct.Background = 0
+	primary := ct.Volume()
+
+	// A coarser secondary, shifted, with a hot spot at a patient point.
+	spot := math32.NewVector3(20, 12, 20)
+	pet := phantom.New(12, 12, 12, 3)
+	pet.Origin = math32.NewVector3(2, 2, 5)
+	pet.Background = 0
+	pet.Shapes = []phantom.Shape{phantom.Sphere{Center: spot, Radius: 5, Value: 1000}}
+	secondary := pet.Volume()
+	secondary.DcmData.Window, secondary.DcmData.Level = 500, 1000
+	secondary.DcmData.LUT, _ = volume.BuiltinLUT("hot iron")
+
+	frame := volume.Axial(primary, 20)
+	frame.Cut(primary)
+	frame.Fuse(secondary, 0.5)
+	pixel := func(p *math32.Vector3) (uint8, uint8, uint8) {
+		at := frame.PatientToPlane(p)
+		c := (*frame.Mpr).RGBAAt(int((at.X-frame.Box2f.Min.X)/frame.ImagePixelSize.X), int((at.Y-frame.Box2f.Min.Y)/frame.ImagePixelSize.Y))
+		return c.R, c.G, c.B
+	}
+	// The primary is mid gray; the spot is white in hot iron, the rest of
+	// the secondary black, and past it the primary shows alone.
+	if r, g, b := pixel(spot); r < 180 || g < 180 || b < 180 {
+		t.Errorf("spot %d %d %d", r, g, b)
+	}
+	if r, g, b := pixel(math32.NewVector3(30, 30, 20)); r > 70 || r != g || g != b {
+		t.Errorf("cold secondary %d %d %d", r, g, b)
+	}
+	if r, g, b := pixel(math32.NewVector3(38, 38, 20)); r < 120 || r > 135 || r != g || g != b {
+		t.Errorf("outside the secondary %d %d %d", r, g, b)
+	}
+}
@@ -1,76 +1,117 @@
 package volume
 
 import (
-	"errors"
-	"math"
+	"sort"
 
 	"github.com/g3n/engine/math32"
 )
 
-func getSides(box *math32.Box3, v Volume) []*math32.Ray {
-
-	var edges []*math32.Ray
-	noT := math32.NewMatrix4().Identity().Copy(v.DcmData.Calibration).SetPosition(math32.NewVec3())
-	dirX := math32.NewVector3(1, 0, 0).ApplyMatrix4(noT).Normalize()
-	dirY := math32.NewVector3(0, 1, 0).ApplyMatrix4(noT).Normalize()
-	dirZ := math32.NewVector3(0, 0, 1).ApplyMatrix4(noT).Normalize()
-
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Min.X, box.Min.Y, box.Min.Z), dirX))
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Min.X, box.Max.Y, box.Min.Z), dirX))
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Min.X, box.Min.Y, box.Max.Z), dirX))
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Min.X, box.Max.Y, box.Max.Z), dirX))
-
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Min.X, box.Min.Y, box.Min.Z), dirY))
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Max.X, box.Min.Y, box.Min.Z), dirY))
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Min.X, box.Min.Y, box.Max.Z), dirY))
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Max.X, box.Min.Y, box.Max.Z), dirY))
+// boxEdges pairs the indices of GetCorners' corners joined by an edge, grouped
+// by the voxel axis they run along (x, y, then z).
+var boxEdges = [12][2]int{
+	{0, 2}, {1, 3}, {4, 6}, {5, 7},
+	{0, 1}, {2, 3}, {4, 5}, {6, 7},
+	{0, 4}, {1, 5}, {2, 6}, {3, 7},
+}
 
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Min.X, box.Min.Y, box.Min.Z), dirZ))
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Max.X, box.Min.Y, box.Min.Z), dirZ))
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Min.X, box.Max.Y, box.Min.Z), dirZ))
-	edges = append(edges, math32.NewRay(math32.NewVector3(box.Max.X, box.Max.Y, box.Min.Z), dirZ))
+func getSides(aabb AABB) []*math32.Ray {
 
+	var edges []*math32.Ray
+	for _, e := range boxEdges {
+		a := aabb.CalibratedCorners[e[0]]
+		b := aabb.CalibratedCorners[e[1]]
+		dir := math32.NewVec3().SubVectors(&b, &a).Normalize()
+		edges = append(edges, math32.NewRay(math32.NewVec3().Copy(&a), dir))
+	}
 	return edges
 }
-func rp2(ray *math32.Ray, plane *math32.Plane, aabb AABB) (math32.Vector3, error) {
 
-	intersection := ray.IntersectBox(aabb.Box, nil)
-	if intersection != nil {
-		return *intersection, nil
-	}
-	return *math32.NewVec3(), errors.New("no intersection")
+// planeNormal recovers the normal of p, which math32.Plane does not expose.
+func planeNormal(p *math32.Plane) *math32.Vector3 {
+	d0 := p.DistanceToPoint(math32.NewVec3())
+	return math32.NewVector3(
+		p.DistanceToPoint(math32.NewVector3(1, 0, 0))-d0,
+		p.DistanceToPoint(math32.NewVector3(0, 1, 0))-d0,
+		p.DistanceToPoint(math32.NewVector3(0, 0, 1))-d0,
+	)
 }
 
-func rp(ray *math32.Ray, plane *math32.Plane, aabb AABB) (math32.Vector3, error) {
+// planeConstant recovers the constant of p, likewise hidden.
+func planeConstant(p *math32.Plane) float32 {
+	return p.DistanceToPoint(math32.NewVec3())
+}
 
-	ct := math32.Vector3{}
-	i := ray.IntersectPlane(plane, &ct)
-	isXNan := math.IsNaN(float64(i.X))
-	isYNan := math.IsNaN(float64(i.Y))
-	isZNan := math.IsNaN(float64(i.Z))
-	if i != nil && !isXNan && !isYNan && !isZNan {
-		if aabb.Box.ContainsPoint(i) {
-			return math32.Vector3{X: i.X, Y: i.Y, Z: i.Z}, nil
-		} else {
-			return *math32.NewVec3(), errors.New("no intersection")
+// PlanePolygon clips the box spanned by corners (ordered as in GetCorners)
+// against plane. It returns the convex intersection polygon with duplicate
+// vertices removed and the rest wound counterclockwise around the plane normal.
+// Planes touching the box in a vertex or an edge yield one or two points.
+func PlanePolygon(corners []math32.Vector3, plane *math32.Plane) []math32.Vector3 {
+	eps := 1e-4 * corners[0].DistanceTo(&corners[7])
+	var pts []math32.Vector3
+	for _, e := range boxEdges {
+		a := corners[e[0]]
+		b := corners[e[1]]
+		da := plane.DistanceToPoint(&a)
+		db := plane.DistanceToPoint(&b)
+		if math32.Abs(da) <= eps {
+			pts = append(pts, a)
+		}
+		if math32.Abs(db) <= eps {
+			pts = append(pts, b)
 		}
-	} else {
-		return math32.Vector3{}, errors.New("no intersection")
+		if (da < -eps && db > eps) || (da > eps && db < -eps) {
+			t := da / (da - db)
+			pt := math32.NewVec3().SubVectors(&b, &a).MultiplyScalar(t).Add(&a)
+			pts = append(pts, *pt)
+		}
+	}
+	pts = dedup(pts, eps)
+	if len(pts) < 3 {
+		return pts
+	}
+
+	center := math32.NewVec3()
+	for i := range pts {
+		center.Add(&pts[i])
+	}
+	center.DivideScalar(float32(len(pts)))
+	n := planeNormal(plane).Normalize()
+	u := math32.NewVec3().SubVectors(&pts[0], center).Normalize()
+	v := math32.NewVec3().CrossVectors(n, u)
+	angle := func(p *math32.Vector3) float32 {
+		d := math32.NewVec3().SubVectors(p, center)
+		return math32.Atan2(d.Dot(v), d.Dot(u))
 	}
+	sort.Slice(pts, func(i, j int) bool { return angle(&pts[i]) < angle(&pts[j]) })
+	return pts
+}
 
+func dedup(vecs []math32.Vector3, eps float32) []math32.Vector3 {
+	var acc []math32.Vector3
+	for i := range vecs {
+		unique := true
+		for j := range acc {
+			if vecs[i].DistanceTo(&acc[j]) <= eps {
+				unique = false
+				break
+			}
+		}
+		if unique {
+			acc = append(acc, vecs[i])
+		}
+	}
+	return acc
 }
 
 func ToPlaneUV(pts []math32.Vector3, pNormal *math32.Vector3, origin *math32.Vector3, basis *math32.Matrix4) []*math32.Vector2 {
 
 	var res []*math32.Vector2
+	xDir := math32.NewVector3(1, 0, 0).ApplyMatrix4(basis).Normalize()
+	yDir := math32.NewVector3(0, 1, 0).ApplyMatrix4(basis).Normalize()
 	for _, pt := range pts {
 		ptCopy := math32.NewVector3(pt.X, pt.Y, pt.Z)
 
 		v := ptCopy.Sub(origin)
-		xDir := math32.NewVector3(1, 0, 0)
-		yDir := math32.NewVector3(0, 1, 0)
-		v.ApplyMatrix4(basis)
-
 		onPlane := math32.NewVector2(v.Dot(xDir), v.Dot(yDir))
 		res = append(res, onPlane)
 	}
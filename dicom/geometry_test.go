@@ -0,0 +1,54 @@
+package volume
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func unitCube() []math32.Vector3 {
+	v := Volume{DcmData: NewDcmData(10, 10, 10, math32.NewMatrix4(), math32.NewVec3(), math32.NewVector3(1, 1, 1))}
+	return v.GetCorners().CalibratedCorners
+}
+
+func TestPlanePolygon(t *testing.T) {
+	tests := []struct {
+		name   string
+		normal *math32.Vector3
+		point  *math32.Vector3
+		n      int
+	}{
+		{"axial", math32.NewVector3(0, 0, 1), math32.NewVector3(5, 5, 5), 4},
+		{"face", math32.NewVector3(0, 0, 1), math32.NewVector3(0, 0, 10), 4},
+		{"hexagon", math32.NewVector3(1, 1, 1), math32.NewVector3(5, 5, 5), 6},
+		{"triangle", math32.NewVector3(1, 1, 1), math32.NewVector3(1, 1, 1), 3},
+		{"corner", math32.NewVector3(1, 1, 1), math32.NewVector3(0, 0, 0), 1},
+		{"outside", math32.NewVector3(0, 1, 0), math32.NewVector3(0, 20, 0), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := math32.NewVec3().Copy(tt.normal).Normalize()
+			plane := math32.NewPlane(nil, 0).SetFromNormalAndCoplanarPoint(n, tt.point)
+			poly := PlanePolygon(unitCube(), plane)
+			if len(poly) != tt.n {
+				t.Fatalf("got %d vertices %v, want %d", len(poly), poly, tt.n)
+			}
+			for i := range poly {
+				if d := plane.DistanceToPoint(&poly[i]); math32.Abs(d) > 1e-4 {
+					t.Errorf("vertex %v is %v from the plane", poly[i], d)
+				}
+			}
+			if len(poly) < 3 {
+				return
+			}
+			for i := range poly {
+				a, b, c := poly[i], poly[(i+1)%len(poly)], poly[(i+2)%len(poly)]
+				ab := math32.NewVec3().SubVectors(&b, &a)
+				bc := math32.NewVec3().SubVectors(&c, &b)
+				if ab.Cross(bc).Dot(n) <= 0 {
+					t.Errorf("vertices %d..%d are not counterclockwise", i, i+2)
+				}
+			}
+		})
+	}
+}
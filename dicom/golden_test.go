@@ -0,0 +1,227 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"awesomeProject/phantom"
+	"encoding/json"
+	"flag"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+// Regenerate with: go test ./dicom -run Golden -update
+var update = flag.Bool("update", false, "rewrite golden files")
+
+const (
+	geometryTolerance = 1e-3
+	pixelTolerance    = 2
+	maxBadPixels      = 0.001
+)
+
+type goldenFrame struct {
+	Intersections  [][3]float32
+	Box2f          [2][2]float32
+	ImageSize      [2]float32
+	ImageSizeInMm  [2]float32
+	ImagePixelSize [2]float32
+}
+
+type goldenCase struct {
+	name string
+	cut  func(v volume.Volume) volume.SliceFrame
+}
+
+func sheppLogan() *phantom.Phantom {
+	p := phantom.New(64, 64, 48, 2)
+	p.Spacing = math32.NewVector3(2, 2, 2.5)
+	p.Shapes = []phantom.Shape{phantom.SheppLogan{
+		Center:   math32.NewVector3(63, 63, 58.75),
+		HalfSize: math32.NewVector3(60, 60, 55),
+		Scale:    1000,
+	}}
+	return p
+}
+
+func obliqueSpheres() *phantom.Phantom {
+	p := phantom.New(40, 48, 32, 3)
+	p.RowDir = math32.NewVector3(1, 1, 0)
+	p.ColDir = math32.NewVector3(-1, 1, 0)
+	p.Shapes = []phantom.Shape{
+		phantom.Checkerboard{Origin: math32.NewVec3(), Size: 24, Low: -500, High: 0},
+		phantom.Sphere{Center: p.VoxelCenter(20, 24, 16), Radius: 30, Value: 800},
+		phantom.Cylinder{Center: p.VoxelCenter(20, 24, 16), Axis: math32.NewVector3(0, 0, 1), Radius: 8, Height: 200, Value: -800},
+	}
+	return p
+}
+
+func cases() []goldenCase {
+	rotation := math32.NewMatrix4().MakeRotationFromQuaternion(
+		math32.NewQuaternion(0, 0, 0, 1).SetFromEuler(math32.NewVector3(0.3, -0.4, 0.2)))
+	return []goldenCase{
+		{"axial", func(v volume.Volume) volume.SliceFrame { return volume.Axial(v, v.DcmData.Depth/2) }},
+		{"coronal", func(v volume.Volume) volume.SliceFrame { return volume.Coronal(v, v.DcmData.Rows/2) }},
+		{"sagittal", func(v volume.Volume) volume.SliceFrame { return volume.Sagittal(v, v.DcmData.Cols/2) }},
+		{"free", func(v volume.Volume) volume.SliceFrame { return volume.FreeRotation(v, rotation) }},
+	}
+}
+
+func TestGolden(t *testing.T) {
+	phantoms := []struct {
+		name string
+		p    *phantom.Phantom
+	}{
+		{"shepplogan", sheppLogan()},
+		{"oblique", obliqueSpheres()},
+	}
+	for _, ph := range phantoms {
+		v := ph.p.Volume()
+		for _, c := range cases() {
+			name := ph.name + "_" + c.name
+			t.Run(name, func(t *testing.T) {
+				frame := c.cut(v)
+				frame.Cut(v)
+				checkGolden(t, name, frame)
+			})
+		}
+	}
+}
+
+func checkGolden(t *testing.T, name string, frame volume.SliceFrame) {
+	imgPath := filepath.Join("testdata", "golden", name+".png")
+	geomPath := filepath.Join("testdata", "golden", name+".json")
+	got := toGolden(frame)
+	// Every case cuts through its phantom, so an empty reformat is a bug and
+	// never a golden.
+	if (*frame.Mpr).Bounds().Empty() || len(frame.Intersections) < 3 {
+		t.Fatalf("empty reformat: %d intersections, image %v", len(frame.Intersections), (*frame.Mpr).Bounds())
+	}
+
+	if *update {
+		writeGolden(t, imgPath, geomPath, *frame.Mpr, got)
+		return
+	}
+
+	b, err := os.ReadFile(geomPath)
+	if err != nil {
+		t.Fatalf("missing golden, run with -update: %v", err)
+	}
+	var want goldenFrame
+	if err := json.Unmarshal(b, &want); err != nil {
+		t.Fatal(err)
+	}
+	compareGeometry(t, got, want)
+
+	f, err := os.Open(imgPath)
+	if err != nil {
+		t.Fatalf("missing golden, run with -update: %v", err)
+	}
+	defer f.Close()
+	wantImg, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareImages(t, *frame.Mpr, wantImg)
+}
+
+func toGolden(frame volume.SliceFrame) goldenFrame {
+	g := goldenFrame{
+		Box2f:          [2][2]float32{{frame.Box2f.Min.X, frame.Box2f.Min.Y}, {frame.Box2f.Max.X, frame.Box2f.Max.Y}},
+		ImageSize:      [2]float32{frame.ImageSize.X, frame.ImageSize.Y},
+		ImageSizeInMm:  [2]float32{frame.ImageSizeInMm.X, frame.ImageSizeInMm.Y},
+		ImagePixelSize: [2]float32{frame.ImagePixelSize.X, frame.ImagePixelSize.Y},
+	}
+	for _, p := range frame.Intersections {
+		g.Intersections = append(g.Intersections, [3]float32{p.X, p.Y, p.Z})
+	}
+	return g
+}
+
+func writeGolden(t *testing.T, imgPath string, geomPath string, img *image.RGBA, g goldenFrame) {
+	b, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(geomPath, append(b, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(imgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func near(a []float32, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > geometryTolerance*math.Max(1, math.Abs(float64(b[i]))) {
+			return false
+		}
+	}
+	return true
+}
+
+func compareGeometry(t *testing.T, got goldenFrame, want goldenFrame) {
+	t.Helper()
+	if len(got.Intersections) != len(want.Intersections) {
+		t.Errorf("Intersections: got %d points, want %d", len(got.Intersections), len(want.Intersections))
+	} else {
+		for i := range want.Intersections {
+			if !near(got.Intersections[i][:], want.Intersections[i][:]) {
+				t.Errorf("Intersections[%d] = %v, want %v", i, got.Intersections[i], want.Intersections[i])
+			}
+		}
+	}
+	if !near(got.Box2f[0][:], want.Box2f[0][:]) || !near(got.Box2f[1][:], want.Box2f[1][:]) {
+		t.Errorf("Box2f = %v, want %v", got.Box2f, want.Box2f)
+	}
+	if !near(got.ImageSize[:], want.ImageSize[:]) {
+		t.Errorf("ImageSize = %v, want %v", got.ImageSize, want.ImageSize)
+	}
+	if !near(got.ImageSizeInMm[:], want.ImageSizeInMm[:]) {
+		t.Errorf("ImageSizeInMm = %v, want %v", got.ImageSizeInMm, want.ImageSizeInMm)
+	}
+	if !near(got.ImagePixelSize[:], want.ImagePixelSize[:]) {
+		t.Errorf("ImagePixelSize = %v, want %v", got.ImagePixelSize, want.ImagePixelSize)
+	}
+}
+
+func compareImages(t *testing.T, got *image.RGBA, want image.Image) {
+	t.Helper()
+	if !got.Bounds().Eq(want.Bounds()) {
+		t.Fatalf("image bounds = %v, want %v", got.Bounds(), want.Bounds())
+	}
+	bad := 0
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			gr, gg, gb, _ := got.At(x, y).RGBA()
+			wr, wg, wb, _ := want.At(x, y).RGBA()
+			if diff(gr, wr) > pixelTolerance || diff(gg, wg) > pixelTolerance || diff(gb, wb) > pixelTolerance {
+				bad++
+			}
+		}
+	}
+	if limit := int(maxBadPixels * float64(b.Dx()*b.Dy())); bad > limit {
+		t.Errorf("%d pixels differ from golden (limit %d)", bad, limit)
+	}
+}
+
+func diff(a uint32, b uint32) uint32 {
+	a, b = a>>8, b>>8
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
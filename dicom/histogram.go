@@ -0,0 +1,40 @@
+package volume
+
+// Histogram counts the volume's values in bins of equal width spanning its
+// lowest value to its highest, and returns those two alongside.
+func (volume Volume) Histogram(bins int) ([]int, float32, float32) {
+	counts := make([]int, bins)
+	min, max, first := float32(0), float32(0), true
+	for _, slice := range volume.Values {
+		for _, row := range slice {
+			for _, value := range row {
+				if first || value < min {
+					min = value
+				}
+				if first || value > max {
+					max = value
+				}
+				first = false
+			}
+		}
+	}
+	if bins == 0 || first {
+		return counts, min, max
+	}
+	scale := float32(bins) / (max - min)
+	if max == min {
+		scale = 0
+	}
+	for _, slice := range volume.Values {
+		for _, row := range slice {
+			for _, value := range row {
+				i := int((value - min) * scale)
+				if i >= bins {
+					i = bins - 1
+				}
+				counts[i]++
+			}
+		}
+	}
+	return counts, min, max
+}
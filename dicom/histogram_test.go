@@ -0,0 +1,18 @@
+package volume_test
+
+import (
+	"awesomeProject/phantom"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	p := phantom.New(10, 10, 10, 1)
+	p.Shapes = []phantom.Shape{phantom.Sphere{Center: p.VoxelCenter(5, 5, 5), Radius: 3, Value: 100}}
+	counts, min, max := p.Volume().Histogram(4)
+	if min != -1000 || max != 100 {
+		t.Fatalf("range %v..%v", min, max)
+	}
+	if counts[0]+counts[3] != 1000 || counts[1] != 0 || counts[2] != 0 || counts[3] == 0 {
+		t.Errorf("counts %v", counts)
+	}
+}
@@ -0,0 +1,360 @@
+package volume
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/g3n/engine/math32"
+)
+
+// Mesh is a triangle surface in patient coordinates. Each three Indices
+// make a triangle, wound counter-clockwise seen from outside.
+type Mesh struct {
+	Vertices []math32.Vector3
+	Indices  []uint32
+}
+
+// Isosurface extracts the surface where the volume's values cross threshold
+// by marching cubes; values above the threshold are inside. Objects touching
+// the volume's edge are capped half a voxel beyond it, so the surface always
+// closes.
+func Isosurface(v Volume, threshold float32) Mesh {
+	return marchingCubes(v, func(value float32) float32 { return value }, threshold)
+}
+
+// LabelSurface extracts the outline of a label mask loaded as a volume: of
+// the voxels equal to label, or of every non-zero voxel when label is zero.
+func LabelSurface(mask Volume, label float32) Mesh {
+	inside := func(value float32) float32 {
+		if (label == 0 && value != 0) || (label != 0 && value == label) {
+			return 1
+		}
+		return 0
+	}
+	return marchingCubes(mask, inside, 0.5)
+}
+
+// Cube corners are numbered by their offsets, bit 0 along x, bit 1 along y
+// and bit 2 along z. Edges join corners one bit apart.
+var (
+	cubeEdges [12][2]int
+	// cubeFaces lists each face's corners counter-clockwise seen from
+	// outside the cube.
+	cubeFaces = [6][4]int{
+		{0, 4, 6, 2}, // x = 0
+		{1, 3, 7, 5}, // x = 1
+		{0, 1, 5, 4}, // y = 0
+		{2, 6, 7, 3}, // y = 1
+		{0, 2, 3, 1}, // z = 0
+		{4, 5, 7, 6}, // z = 1
+	}
+	// cubeTriangles holds the edges cut by each triangle, three at a time,
+	// for every combination of corners inside.
+	cubeTriangles [256][]int
+)
+
+func init() {
+	n := 0
+	for a := 0; a < 8; a++ {
+		for bit := 1; bit < 8; bit <<= 1 {
+			if a&bit == 0 {
+				cubeEdges[n] = [2]int{a, a | bit}
+				n++
+			}
+		}
+	}
+	edgeOf := func(a int, b int) int {
+		for i, e := range cubeEdges {
+			if (e[0] == a && e[1] == b) || (e[0] == b && e[1] == a) {
+				return i
+			}
+		}
+		return -1
+	}
+	for config := 0; config < 256; config++ {
+		inside := func(corner int) bool { return config&(1<<corner) != 0 }
+		// On each face, a segment runs from where the boundary leaves the
+		// inside to where it next comes back in. A face with two inside
+		// corners facing each other gets two segments, keeping them apart;
+		// both cubes sharing the face decide alike, so the surface closes.
+		next := map[int]int{}
+		for _, face := range cubeFaces {
+			var exits, entries []int
+			for k := 0; k < 4; k++ {
+				a, b := face[k], face[(k+1)%4]
+				if inside(a) && !inside(b) {
+					exits = append(exits, k)
+				} else if !inside(a) && inside(b) {
+					entries = append(entries, k)
+				}
+			}
+			for _, k := range exits {
+				// The first entry after this exit going round the face.
+				best, bestDist := -1, 5
+				for _, j := range entries {
+					if d := (j - k + 4) % 4; d < bestDist {
+						best, bestDist = j, d
+					}
+				}
+				from := edgeOf(face[k], face[(k+1)%4])
+				to := edgeOf(face[best], face[(best+1)%4])
+				next[from] = to
+			}
+		}
+		// Chain the segments into loops and fan them into triangles.
+		for len(next) > 0 {
+			var start int
+			for start = range next {
+				break
+			}
+			loop := []int{start}
+			for e := next[start]; e != start; e = next[e] {
+				loop = append(loop, e)
+			}
+			for _, e := range loop {
+				delete(next, e)
+			}
+			for i := 1; i+1 < len(loop); i++ {
+				cubeTriangles[config] = append(cubeTriangles[config], loop[0], loop[i+1], loop[i])
+			}
+		}
+	}
+}
+
+// marchingCubes walks every cell between voxel centres, cutting it where
+// field crosses iso. The cells run one voxel past the volume on each side,
+// where the samples are below iso, and the cuts to them fall half way.
+// Vertices on shared edges are shared.
+func marchingCubes(v Volume, field func(float32) float32, iso float32) Mesh {
+	var mesh Mesh
+	cols, rows, depth := v.DcmData.Cols, v.DcmData.Rows, v.DcmData.Depth
+	outside := func(x, y, z int) bool {
+		return x < 0 || y < 0 || z < 0 || x >= cols || y >= rows || z >= depth
+	}
+	below := float32(math.Inf(-1))
+	at := func(x, y, z int) float32 {
+		if outside(x, y, z) {
+			return below
+		}
+		return field(v.Values[z][y][x])
+	}
+	// Vertices by the voxel and axis of the edge they lie on, counting from
+	// the corner of the border.
+	vertexOf := map[int]uint32{}
+	edgeVertex := func(x, y, z int, edge [2]int) uint32 {
+		a, b := edge[0], edge[1]
+		ax, ay, az := x+a&1, y+a>>1&1, z+a>>2&1
+		bx, by, bz := x+b&1, y+b>>1&1, z+b>>2&1
+		axis := bits.TrailingZeros(uint(a ^ b))
+		key := (((az+1)*(rows+2)+ay+1)*(cols+2)+ax+1)*3 + axis
+		if i, ok := vertexOf[key]; ok {
+			return i
+		}
+		va, vb := at(ax, ay, az), at(bx, by, bz)
+		t := float32(0.5)
+		if va != vb && !outside(ax, ay, az) && !outside(bx, by, bz) {
+			t = (iso - va) / (vb - va)
+		}
+		p := math32.NewVector3(float32(ax), float32(ay), float32(az))
+		p.SetComponent(axis, p.Component(axis)+t)
+		p.ApplyMatrix4(v.DcmData.Calibration)
+		i := uint32(len(mesh.Vertices))
+		mesh.Vertices = append(mesh.Vertices, *p)
+		vertexOf[key] = i
+		return i
+	}
+	var corners [8]float32
+	for z := -1; z < depth; z++ {
+		for y := -1; y < rows; y++ {
+			for x := -1; x < cols; x++ {
+				config := 0
+				for c := 0; c < 8; c++ {
+					corners[c] = at(x+c&1, y+c>>1&1, z+c>>2&1)
+					if corners[c] > iso {
+						config |= 1 << c
+					}
+				}
+				for _, edge := range cubeTriangles[config] {
+					mesh.Indices = append(mesh.Indices, edgeVertex(x, y, z, cubeEdges[edge]))
+				}
+			}
+		}
+	}
+	// A left-handed calibration mirrors the surface, turning it inside out.
+	if v.DcmData.Calibration.Determinant() < 0 {
+		mesh.flip()
+	}
+	return mesh
+}
+
+func (mesh *Mesh) flip() {
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		mesh.Indices[i+1], mesh.Indices[i+2] = mesh.Indices[i+2], mesh.Indices[i+1]
+	}
+}
+
+// Normals returns a unit normal per vertex, averaged over the triangles
+// around it weighted by their area.
+func (mesh Mesh) Normals() []math32.Vector3 {
+	normals := make([]math32.Vector3, len(mesh.Vertices))
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		a, b, c := mesh.Indices[i], mesh.Indices[i+1], mesh.Indices[i+2]
+		n := mesh.faceNormal(a, b, c)
+		for _, j := range []uint32{a, b, c} {
+			normals[j].Add(n)
+		}
+	}
+	for i := range normals {
+		normals[i].Normalize()
+	}
+	return normals
+}
+
+// faceNormal is the normal of triangle a, b, c scaled by twice its area.
+func (mesh Mesh) faceNormal(a uint32, b uint32, c uint32) *math32.Vector3 {
+	ab := math32.NewVec3().SubVectors(&mesh.Vertices[b], &mesh.Vertices[a])
+	ac := math32.NewVec3().SubVectors(&mesh.Vertices[c], &mesh.Vertices[a])
+	return ab.Cross(ac)
+}
+
+// Volume returns the volume enclosed by a closed mesh in mm³, positive when
+// its triangles face outwards.
+func (mesh Mesh) Volume() float32 {
+	var sum float64
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		a := mesh.Vertices[mesh.Indices[i]]
+		b := mesh.Vertices[mesh.Indices[i+1]]
+		c := mesh.Vertices[mesh.Indices[i+2]]
+		sum += float64(a.Dot(math32.NewVec3().CrossVectors(&b, &c))) / 6
+	}
+	return float32(sum)
+}
+
+// neighbours lists the vertices sharing an edge with each vertex.
+func (mesh Mesh) neighbours() [][]uint32 {
+	sets := make([]map[uint32]bool, len(mesh.Vertices))
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		tri := mesh.Indices[i : i+3]
+		for k := 0; k < 3; k++ {
+			a, b := tri[k], tri[(k+1)%3]
+			if sets[a] == nil {
+				sets[a] = map[uint32]bool{}
+			}
+			if sets[b] == nil {
+				sets[b] = map[uint32]bool{}
+			}
+			sets[a][b], sets[b][a] = true, true
+		}
+	}
+	lists := make([][]uint32, len(sets))
+	for i, set := range sets {
+		for j := range set {
+			lists[i] = append(lists[i], j)
+		}
+	}
+	return lists
+}
+
+// Smooth relaxes the surface with Taubin's filter, iterations times: a
+// Laplacian step that smooths followed by a negative one that undoes the
+// shrinking plain Laplacian smoothing would cause.
+func (mesh *Mesh) Smooth(iterations int) {
+	const lambda, mu = 0.5, -0.53
+	if iterations <= 0 {
+		return
+	}
+	around := mesh.neighbours()
+	moved := make([]math32.Vector3, len(mesh.Vertices))
+	step := func(factor float32) {
+		for i, ns := range around {
+			moved[i] = mesh.Vertices[i]
+			if len(ns) == 0 {
+				continue
+			}
+			var mean math32.Vector3
+			for _, j := range ns {
+				mean.Add(&mesh.Vertices[j])
+			}
+			mean.DivideScalar(float32(len(ns))).Sub(&mesh.Vertices[i])
+			moved[i].Add(mean.MultiplyScalar(factor))
+		}
+		copy(mesh.Vertices, moved)
+	}
+	for i := 0; i < iterations; i++ {
+		step(lambda)
+		step(mu)
+	}
+}
+
+// Decimate simplifies the mesh by vertex clustering: vertices in the same
+// cube of side cell mm merge into their mean, and triangles left with less
+// than three distinct corners go.
+func (mesh Mesh) Decimate(cell float32) Mesh {
+	if cell <= 0 || len(mesh.Vertices) == 0 {
+		return mesh
+	}
+	type key [3]int64
+	keyOf := func(p math32.Vector3) key {
+		return key{
+			int64(math.Floor(float64(p.X / cell))),
+			int64(math.Floor(float64(p.Y / cell))),
+			int64(math.Floor(float64(p.Z / cell))),
+		}
+	}
+	var out Mesh
+	clusters := map[key]uint32{}
+	counts := []float32{}
+	remap := make([]uint32, len(mesh.Vertices))
+	for i, p := range mesh.Vertices {
+		k := keyOf(p)
+		j, ok := clusters[k]
+		if !ok {
+			j = uint32(len(out.Vertices))
+			clusters[k] = j
+			out.Vertices = append(out.Vertices, math32.Vector3{})
+			counts = append(counts, 0)
+		}
+		out.Vertices[j].Add(&mesh.Vertices[i])
+		counts[j]++
+		remap[i] = j
+	}
+	for j := range out.Vertices {
+		out.Vertices[j].DivideScalar(counts[j])
+	}
+	seen := map[[3]uint32]bool{}
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		a, b, c := remap[mesh.Indices[i]], remap[mesh.Indices[i+1]], remap[mesh.Indices[i+2]]
+		if a == b || b == c || a == c {
+			continue
+		}
+		// The same triangle may come from several; keep it once, whichever
+		// corner it starts at.
+		tri := [3]uint32{a, b, c}
+		for tri[0] > tri[1] || tri[0] > tri[2] {
+			tri = [3]uint32{tri[1], tri[2], tri[0]}
+		}
+		if seen[tri] {
+			continue
+		}
+		seen[tri] = true
+		out.Indices = append(out.Indices, a, b, c)
+	}
+	return out.compact()
+}
+
+// compact drops the vertices no triangle uses.
+func (mesh Mesh) compact() Mesh {
+	used := make([]int64, len(mesh.Vertices))
+	for i := range used {
+		used[i] = -1
+	}
+	var out Mesh
+	for _, i := range mesh.Indices {
+		if used[i] < 0 {
+			used[i] = int64(len(out.Vertices))
+			out.Vertices = append(out.Vertices, mesh.Vertices[i])
+		}
+		out.Indices = append(out.Indices, uint32(used[i]))
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"awesomeProject/phantom"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestIsosurface(t *testing.T) {
+	p := phantom.New(40, 40, 30, 1.5)
+	p.Spacing = math32.NewVector3(1.5, 1.5, 2)
+	center := p.VoxelCenter(20, 20, 15)
+	const radius = 20
+	p.Shapes = []phantom.Shape{phantom.Sphere{Center: center, Radius: radius, Value: 1000}}
+	v := p.Volume()
+
+	mesh := volume.Isosurface(v, 0)
+	want := float32(4 * math32.Pi / 3 * radius * radius * radius)
+	if got := mesh.Volume(); math32.Abs(got-want)/want > 0.05 {
+		t.Errorf("sphere volume %v, want %v", got, want)
+	}
+	if !closed(mesh) {
+		t.Errorf("surface has open edges")
+	}
+	for _, vertex := range mesh.Vertices {
+		if d := vertex.DistanceTo(center); math32.Abs(d-radius) > 2 {
+			t.Fatalf("vertex %v is %v mm from the centre", vertex, d)
+		}
+	}
+
+	// A sphere cut by the volume's edge is capped half a voxel beyond it.
+	edge := phantom.New(20, 20, 20, 1)
+	edge.Shapes = []phantom.Shape{phantom.Sphere{Center: edge.VoxelCenter(0, 10, 10), Radius: 6, Value: 1000}}
+	capped := volume.Isosurface(edge.Volume(), 0)
+	if !closed(capped) || capped.Volume() <= 0 {
+		t.Errorf("sphere at the edge: closed %v, volume %v", closed(capped), capped.Volume())
+	}
+	for _, vertex := range capped.Vertices {
+		if x := vertex.X - edge.VoxelCenter(0, 0, 0).X; x < -0.5-1e-4 {
+			t.Fatalf("vertex %v beyond the cap", vertex)
+		}
+	}
+
+	smooth := volume.Isosurface(v, 0)
+	smooth.Smooth(5)
+	if got := smooth.Volume(); math32.Abs(got-want)/want > 0.05 {
+		t.Errorf("smoothed volume %v, want %v", got, want)
+	}
+	coarse := mesh.Decimate(4)
+	if len(coarse.Indices) >= len(mesh.Indices)/2 {
+		t.Errorf("decimating kept %d of %d triangles", len(coarse.Indices)/3, len(mesh.Indices)/3)
+	}
+	if got := coarse.Volume(); math32.Abs(got-want)/want > 0.15 {
+		t.Errorf("decimated volume %v, want %v", got, want)
+	}
+
+	labels := volume.LabelSurface(v, 1000)
+	if got := labels.Volume(); math32.Abs(got-want)/want > 0.05 {
+		t.Errorf("label volume %v, want %v", got, want)
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"s.stl", "s.obj", "s.ply"} {
+		path := filepath.Join(dir, name)
+		if err := volume.WriteMesh(path, coarse); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Errorf("%s not written", name)
+		}
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "s.stl")); info.Size() != int64(84+50*len(coarse.Indices)/3) {
+		t.Errorf("stl size %d", info.Size())
+	}
+}
+
+// closed reports whether every edge is shared by exactly two triangles, run
+// in opposite directions.
+func closed(mesh volume.Mesh) bool {
+	edges := map[[2]uint32]int{}
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		for k := 0; k < 3; k++ {
+			edges[[2]uint32{mesh.Indices[i+k], mesh.Indices[i+(k+1)%3]}]++
+		}
+	}
+	for e, n := range edges {
+		if n != 1 || edges[[2]uint32{e[1], e[0]}] != 1 {
+			return false
+		}
+	}
+	return true
+}
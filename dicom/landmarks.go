@@ -0,0 +1,160 @@
+package volume
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/g3n/engine/math32"
+)
+
+// LandmarkFit is the least-squares transform taking landmarks onto their
+// pairs, and how far apart each pair stays, in mm.
+type LandmarkFit struct {
+	Matrix    *math32.Matrix4
+	Scale     float32
+	Residuals []float32
+	RMS       float32
+}
+
+// FitLandmarks finds the rigid or similarity transform taking the moving
+// landmarks onto the fixed ones paired with them, by Horn's closed form with
+// unit quaternions. At least three pairs not on a line are needed.
+func FitLandmarks(moving []math32.Vector3, fixed []math32.Vector3, kind TransformKind) (LandmarkFit, error) {
+	var fit LandmarkFit
+	if kind == Affine {
+		return fit, fmt.Errorf("landmarks fit rigid or similarity transforms")
+	}
+	if len(moving) != len(fixed) {
+		return fit, fmt.Errorf("%d moving landmarks for %d fixed", len(moving), len(fixed))
+	}
+	if len(moving) < 3 {
+		return fit, fmt.Errorf("%d landmark pairs, at least 3 needed", len(moving))
+	}
+	n := float64(len(moving))
+	var ca, cb [3]float64
+	for i := range moving {
+		for k := 0; k < 3; k++ {
+			ca[k] += float64(moving[i].Component(k)) / n
+			cb[k] += float64(fixed[i].Component(k)) / n
+		}
+	}
+	a := make([][3]float64, len(moving))
+	b := make([][3]float64, len(fixed))
+	var sa, sb float64
+	// s[j][k] sums a's j-th coordinate times b's k-th.
+	var s [3][3]float64
+	for i := range moving {
+		for k := 0; k < 3; k++ {
+			a[i][k] = float64(moving[i].Component(k)) - ca[k]
+			b[i][k] = float64(fixed[i].Component(k)) - cb[k]
+			sa += a[i][k] * a[i][k]
+			sb += b[i][k] * b[i][k]
+		}
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				s[j][k] += a[i][j] * b[i][k]
+			}
+		}
+	}
+	if !spread(a, sa) || !spread(b, sb) {
+		return fit, fmt.Errorf("landmarks lie on a line")
+	}
+
+	// The rotation is the unit quaternion maximising q'Nq.
+	xx, xy, xz := s[0][0], s[0][1], s[0][2]
+	yx, yy, yz := s[1][0], s[1][1], s[1][2]
+	zx, zy, zz := s[2][0], s[2][1], s[2][2]
+	q := largestEigenvector([4][4]float64{
+		{xx + yy + zz, yz - zy, zx - xz, xy - yx},
+		{yz - zy, xx - yy - zz, xy + yx, zx + xz},
+		{zx - xz, xy + yx, -xx + yy - zz, yz + zy},
+		{xy - yx, zx + xz, yz + zy, -xx - yy + zz},
+	})
+	rotation := math32.NewMatrix4().MakeRotationFromQuaternion(
+		math32.NewQuaternion(float32(q[1]), float32(q[2]), float32(q[3]), float32(q[0])))
+
+	fit.Scale = 1
+	if kind == Similarity {
+		fit.Scale = float32(math.Sqrt(sb / sa))
+	}
+	linear := rotation.Multiply(math32.NewMatrix4().MakeScale(fit.Scale, fit.Scale, fit.Scale))
+	centroid := math32.NewVector3(float32(ca[0]), float32(ca[1]), float32(ca[2])).ApplyMatrix4(linear)
+	translation := math32.NewVector3(float32(cb[0]), float32(cb[1]), float32(cb[2])).Sub(centroid)
+	fit.Matrix = math32.NewMatrix4().MakeTranslation(translation.X, translation.Y, translation.Z).Multiply(linear)
+
+	var sum float32
+	for i := range moving {
+		d := math32.NewVec3().Copy(&moving[i]).ApplyMatrix4(fit.Matrix).DistanceTo(&fixed[i])
+		fit.Residuals = append(fit.Residuals, d)
+		sum += d * d
+	}
+	fit.RMS = math32.Sqrt(sum / float32(len(moving)))
+	return fit, nil
+}
+
+// spread reports whether centred points, their squares summing to norm, span
+// more than a line.
+func spread(points [][3]float64, norm float64) bool {
+	if norm == 0 {
+		return false
+	}
+	var most float64
+	for i := range points {
+		for j := i + 1; j < len(points); j++ {
+			p, q := points[i], points[j]
+			c := [3]float64{p[1]*q[2] - p[2]*q[1], p[2]*q[0] - p[0]*q[2], p[0]*q[1] - p[1]*q[0]}
+			most = math.Max(most, c[0]*c[0]+c[1]*c[1]+c[2]*c[2])
+		}
+	}
+	return math.Sqrt(most) > 1e-6*norm
+}
+
+// largestEigenvector returns the unit eigenvector of the symmetric m with
+// the largest eigenvalue, by Jacobi rotations.
+func largestEigenvector(m [4][4]float64) [4]float64 {
+	var v [4][4]float64
+	for i := range v {
+		v[i][i] = 1
+	}
+	for sweep := 0; sweep < 50; sweep++ {
+		var off float64
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				off += m[p][q] * m[p][q]
+			}
+		}
+		if off < 1e-24 {
+			break
+		}
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				if m[p][q] == 0 {
+					continue
+				}
+				theta := (m[q][q] - m[p][p]) / (2 * m[p][q])
+				t := math.Copysign(1, theta) / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < 4; k++ {
+					mkp, mkq := m[k][p], m[k][q]
+					m[k][p], m[k][q] = c*mkp-s*mkq, s*mkp+c*mkq
+				}
+				for k := 0; k < 4; k++ {
+					mpk, mqk := m[p][k], m[q][k]
+					m[p][k], m[q][k] = c*mpk-s*mqk, s*mpk+c*mqk
+				}
+				for k := 0; k < 4; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p], v[k][q] = c*vkp-s*vkq, s*vkp+c*vkq
+				}
+			}
+		}
+	}
+	best := 0
+	for i := 1; i < 4; i++ {
+		if m[i][i] > m[best][best] {
+			best = i
+		}
+	}
+	return [4]float64{v[0][best], v[1][best], v[2][best], v[3][best]}
+}
@@ -0,0 +1,50 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestFitLandmarks(t *testing.T) {
+	moving := []math32.Vector3{{X: 10, Y: 0, Z: 0}, {X: 0, Y: 20, Z: 5}, {X: -5, Y: 3, Z: 30}, {X: 12, Y: -8, Z: 4}, {X: 1, Y: 1, Z: 1}}
+	rotation := math32.NewMatrix4().MakeRotationAxis(math32.NewVector3(1, 2, 3).Normalize(), 0.7)
+	for _, c := range []struct {
+		kind  volume.TransformKind
+		scale float32
+	}{{volume.Rigid, 1}, {volume.Similarity, 1.3}} {
+		truth := math32.NewMatrix4().MakeTranslation(4, -7, 12).Multiply(rotation).Multiply(math32.NewMatrix4().MakeScale(c.scale, c.scale, c.scale))
+		fixed := make([]math32.Vector3, len(moving))
+		for i := range moving {
+			fixed[i] = *math32.NewVec3().Copy(&moving[i]).ApplyMatrix4(truth)
+		}
+		fit, err := volume.FitLandmarks(moving, fixed, c.kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range truth {
+			if math32.Abs(fit.Matrix[i]-truth[i]) > 1e-3 {
+				t.Fatalf("%s: fit %v, want %v", c.kind, fit.Matrix, truth)
+			}
+		}
+		if fit.RMS > 1e-3 || math32.Abs(fit.Scale-c.scale) > 1e-4 {
+			t.Errorf("%s: RMS %v, scale %v", c.kind, fit.RMS, fit.Scale)
+		}
+
+		// A misplaced landmark shows in the residuals.
+		fixed[0].X += 2
+		fit, _ = volume.FitLandmarks(moving, fixed, c.kind)
+		if fit.RMS < 0.5 || fit.Residuals[0] <= fit.Residuals[4] {
+			t.Errorf("%s: residuals %v after moving a landmark", c.kind, fit.Residuals)
+		}
+	}
+
+	line := []math32.Vector3{{X: 0}, {X: 1}, {X: 2}}
+	if _, err := volume.FitLandmarks(line, line, volume.Rigid); err == nil {
+		t.Error("fit landmarks on a line")
+	}
+	if _, err := volume.FitLandmarks(moving[:2], moving[:2], volume.Rigid); err == nil {
+		t.Error("fit two landmarks")
+	}
+}
@@ -0,0 +1,125 @@
+package volume
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LUT colours windowed pixels: Mpr shows byte p as Colors[p].
+type LUT struct {
+	Name   string
+	Colors [256]color.RGBA
+}
+
+// LUTNames lists the built-in tables, in menu order.
+var LUTNames = []string{"Gray", "Inverse gray", "Hot iron", "Rainbow", "Jet", "PET"}
+
+// lutStops are colours at evenly spaced points of a table, blended between.
+var lutStops = map[string][]color.RGBA{
+	"Gray":         {{0, 0, 0, 255}, {255, 255, 255, 255}},
+	"Inverse gray": {{255, 255, 255, 255}, {0, 0, 0, 255}},
+	"Hot iron":     {{0, 0, 0, 255}, {255, 0, 0, 255}, {255, 128, 0, 255}, {255, 255, 255, 255}},
+	"Rainbow":      {{128, 0, 255, 255}, {0, 0, 255, 255}, {0, 255, 255, 255}, {0, 255, 0, 255}, {255, 255, 0, 255}, {255, 0, 0, 255}},
+	"Jet":          {{0, 0, 128, 255}, {0, 0, 255, 255}, {0, 128, 255, 255}, {0, 255, 255, 255}, {128, 255, 128, 255}, {255, 255, 0, 255}, {255, 128, 0, 255}, {255, 0, 0, 255}, {128, 0, 0, 255}},
+	"PET":          {{0, 0, 0, 255}, {0, 0, 128, 255}, {128, 0, 128, 255}, {255, 0, 0, 255}, {255, 160, 0, 255}, {255, 255, 255, 255}},
+}
+
+// BuiltinLUT returns the table named in LUTNames.
+func BuiltinLUT(name string) (*LUT, error) {
+	for _, n := range LUTNames {
+		if strings.EqualFold(n, name) {
+			return gradientLUT(n, lutStops[n]), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown lookup table %q", name)
+}
+
+func gradientLUT(name string, stops []color.RGBA) *LUT {
+	lut := &LUT{Name: name}
+	last := len(stops) - 1
+	for i := range lut.Colors {
+		pos := float32(i) / 255 * float32(last)
+		k := int(pos)
+		if k >= last {
+			lut.Colors[i] = stops[last]
+			continue
+		}
+		t := pos - float32(k)
+		mix := func(a uint8, b uint8) uint8 { return uint8(float32(a) + (float32(b)-float32(a))*t + 0.5) }
+		a, b := stops[k], stops[k+1]
+		lut.Colors[i] = color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+	}
+	return lut
+}
+
+// LoadLUT reads a table from a file of 768 bytes, the reds, greens and blues
+// of 256 entries in turn, or from text with a red, green and blue from 0 to
+// 255 per line, stretched over the table when there are fewer than 256.
+// Lines starting with # are comments. The table is named after the file.
+func LoadLUT(path string) (*LUT, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if len(data) == 768 && !isText(data) {
+		lut := &LUT{Name: name}
+		for i := range lut.Colors {
+			lut.Colors[i] = color.RGBA{data[i], data[256+i], data[512+i], 255}
+		}
+		return lut, nil
+	}
+	var stops []color.RGBA
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var r, g, b int
+		if _, err := fmt.Sscanf(strings.NewReplacer(",", " ", "\t", " ").Replace(text), "%d %d %d", &r, &g, &b); err != nil {
+			return nil, fmt.Errorf("%s:%d: want red green blue", path, line)
+		}
+		for _, c := range []int{r, g, b} {
+			if c < 0 || c > 255 {
+				return nil, fmt.Errorf("%s:%d: colours run from 0 to 255", path, line)
+			}
+		}
+		stops = append(stops, color.RGBA{uint8(r), uint8(g), uint8(b), 255})
+	}
+	if len(stops) < 2 || len(stops) > 256 {
+		return nil, fmt.Errorf("%s: %d colours, want 2 to 256", path, len(stops))
+	}
+	return gradientLUT(name, stops), nil
+}
+
+func isText(data []byte) bool {
+	for _, b := range data {
+		if b != '\n' && b != '\r' && b != '\t' && (b < ' ' || b > '~') {
+			return false
+		}
+	}
+	return true
+}
+
+// Bar draws the table as a colour bar width x height pixels, the top of the
+// window at the top.
+func (lut *LUT) Bar(width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		c := lut.Colors[255]
+		if height > 1 {
+			c = lut.Colors[255-y*255/(height-1)]
+		}
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
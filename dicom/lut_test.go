@@ -0,0 +1,56 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLUT(t *testing.T) {
+	for _, name := range volume.LUTNames {
+		lut, err := volume.BuiltinLUT(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lut.Colors[0] == lut.Colors[255] {
+			t.Errorf("%s has the same colour at both ends", name)
+		}
+	}
+	if _, err := volume.BuiltinLUT("sepia"); err == nil {
+		t.Error("unknown table loaded")
+	}
+
+	hot, _ := volume.BuiltinLUT("hot iron")
+	img := volume.Mpr([]byte{0, 128, 255}, 3, 1, volume.DcmData{LUT: hot}, false)
+	for x, want := range []color.RGBA{{0, 0, 0, 255}, hot.Colors[128], {255, 255, 255, 255}} {
+		if got := img.RGBAAt(x, 0); got != want {
+			t.Errorf("pixel %d is %v, want %v", x, got, want)
+		}
+	}
+
+	dir := t.TempDir()
+	text := filepath.Join(dir, "blue.txt")
+	if err := os.WriteFile(text, []byte("# black to blue\n0 0 0\n0, 0, 255\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lut, err := volume.LoadLUT(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lut.Name != "blue" || lut.Colors[255] != (color.RGBA{0, 0, 255, 255}) || lut.Colors[128].B != 128 {
+		t.Errorf("text table %s: %v, %v", lut.Name, lut.Colors[128], lut.Colors[255])
+	}
+	raw := make([]byte, 768)
+	for i := 0; i < 256; i++ {
+		raw[i] = byte(255 - i)
+	}
+	binary := filepath.Join(dir, "inverse.lut")
+	if err := os.WriteFile(binary, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if lut, err = volume.LoadLUT(binary); err != nil || lut.Colors[0] != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("binary table: %v, %v", lut, err)
+	}
+}
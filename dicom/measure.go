@@ -0,0 +1,277 @@
+package volume
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/g3n/engine/math32"
+)
+
+type MeasureKind int
+
+const (
+	Distance MeasureKind = iota
+	Angle
+	Polyline
+	Ellipse
+	Freehand
+)
+
+var measureKindNames = [...]string{"Distance", "Angle", "Polyline", "Ellipse", "Freehand"}
+
+func (kind MeasureKind) String() string {
+	return enumName(measureKindNames[:], "MeasureKind", int(kind))
+}
+
+func (kind MeasureKind) MarshalText() ([]byte, error) {
+	if kind < 0 || int(kind) >= len(measureKindNames) {
+		return nil, fmt.Errorf("invalid measurement kind %d", int(kind))
+	}
+	return []byte(kind.String()), nil
+}
+
+func (kind *MeasureKind) UnmarshalText(text []byte) error {
+	for i, name := range measureKindNames {
+		if name == string(text) {
+			*kind = MeasureKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown measurement kind %q", text)
+}
+
+// enumName returns the name of value i of an enumerated type, or the type
+// and number when it has none, as the stringer tool does.
+func enumName(names []string, typ string, i int) string {
+	if i < 0 || i >= len(names) {
+		return fmt.Sprintf("%s(%d)", typ, i)
+	}
+	return names[i]
+}
+
+// Measurement is a calibrated measurement placed on a slice plane. Its points
+// are patient coordinates in mm, so it stays put while the planes move and
+// shows again whenever a plane passes through it. An Ellipse is given, as in
+// DICOM SR, by the end points of its major axis followed by those of its
+// minor axis.
+type Measurement struct {
+	Kind   MeasureKind
+	Points []math32.Vector3
+}
+
+// RoiStats summarises the values inside a region of interest; Area is in mm².
+type RoiStats struct {
+	Area  float32
+	Mean  float32
+	Std   float32
+	Min   float32
+	Max   float32
+	Count int
+}
+
+// IsRegion reports whether the measurement encloses an area.
+func (m Measurement) IsRegion() bool {
+	return m.Kind == Ellipse || m.Kind == Freehand
+}
+
+// Length returns the summed length of the segments between the points in mm.
+func (m Measurement) Length() float32 {
+	var length float32
+	for i := 1; i < len(m.Points); i++ {
+		length += m.Points[i].DistanceTo(&m.Points[i-1])
+	}
+	return length
+}
+
+// Degrees returns the angle at the second point between the first and third,
+// in degrees.
+func (m Measurement) Degrees() float32 {
+	if len(m.Points) < 3 {
+		return 0
+	}
+	a := math32.NewVec3().SubVectors(&m.Points[0], &m.Points[1])
+	b := math32.NewVec3().SubVectors(&m.Points[2], &m.Points[1])
+	return math32.RadToDeg(a.AngleTo(b))
+}
+
+// OnPlane reports whether every point lies within tolerance mm of the frame's
+// plane.
+func (m Measurement) OnPlane(frame SliceFrame, tolerance float32) bool {
+	if frame.RotatedFrame.Plane == nil || len(m.Points) == 0 {
+		return false
+	}
+	for i := range m.Points {
+		if math32.Abs(frame.RotatedFrame.Plane.DistanceToPoint(&m.Points[i])) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// EllipseFromCorners returns the ellipse inscribed in the rectangle with
+// opposite corners a and b, its sides along the frame's image axes.
+func EllipseFromCorners(frame SliceFrame, a *math32.Vector3, b *math32.Vector3) Measurement {
+	pa := frame.PatientToPlane(a)
+	pb := frame.PatientToPlane(b)
+	cx, cy := (pa.X+pb.X)/2, (pa.Y+pb.Y)/2
+	rx, ry := math32.Abs(pb.X-pa.X)/2, math32.Abs(pb.Y-pa.Y)/2
+	points := []math32.Vector3{
+		*frame.PlaneToPatient(cx-rx, cy), *frame.PlaneToPatient(cx+rx, cy),
+		*frame.PlaneToPatient(cx, cy-ry), *frame.PlaneToPatient(cx, cy+ry),
+	}
+	if ry > rx {
+		points = append(points[2:], points[:2]...)
+	}
+	return Measurement{Kind: Ellipse, Points: points}
+}
+
+// axes returns the centre and semi axes of an ellipse.
+func (m Measurement) axes() (*math32.Vector3, *math32.Vector3, *math32.Vector3) {
+	center := math32.NewVec3().AddVectors(&m.Points[0], &m.Points[1]).MultiplyScalar(0.5)
+	major := math32.NewVec3().SubVectors(&m.Points[1], &m.Points[0]).MultiplyScalar(0.5)
+	minor := math32.NewVec3().SubVectors(&m.Points[3], &m.Points[2]).MultiplyScalar(0.5)
+	return center, major, minor
+}
+
+// Complete reports whether the measurement has the points its kind needs and
+// they do not all coincide.
+func (m Measurement) Complete() bool {
+	need := map[MeasureKind]int{Distance: 2, Angle: 3, Polyline: 2, Ellipse: 4, Freehand: 3}[m.Kind]
+	if len(m.Points) < need {
+		return false
+	}
+	if m.Kind == Ellipse {
+		_, major, minor := m.axes()
+		return major.Length() > 0 && minor.Length() > 0
+	}
+	for i := range m.Points {
+		if m.Points[i] != m.Points[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// Outline returns the measurement projected on the frame's plane, in the same
+// plane coordinates as Box2f. Regions come back as closed polygons without
+// the closing point repeated.
+func (m Measurement) Outline(frame SliceFrame) []math32.Vector2 {
+	var pts []math32.Vector2
+	if m.Kind != Ellipse || len(m.Points) < 4 {
+		for i := range m.Points {
+			pts = append(pts, *frame.PatientToPlane(&m.Points[i]))
+		}
+		return pts
+	}
+	const segments = 64
+	center, major, minor := m.axes()
+	for i := 0; i < segments; i++ {
+		t := 2 * math32.Pi * float32(i) / segments
+		p := math32.NewVec3().Copy(center).
+			Add(math32.NewVec3().Copy(major).MultiplyScalar(math32.Cos(t))).
+			Add(math32.NewVec3().Copy(minor).MultiplyScalar(math32.Sin(t)))
+		pts = append(pts, *frame.PatientToPlane(p))
+	}
+	return pts
+}
+
+// Frame returns a slice frame through the plane of a region.
+func (m Measurement) Frame(v Volume) SliceFrame {
+	center := math32.NewVec3()
+	for i := range m.Points {
+		center.Add(&m.Points[i])
+	}
+	center.DivideScalar(float32(len(m.Points)))
+	normal := math32.NewVec3()
+	if m.Kind == Ellipse {
+		_, major, minor := m.axes()
+		normal.CrossVectors(major, minor)
+	} else {
+		// Newell's method, robust for hand drawn outlines
+		for i := range m.Points {
+			a, b := m.Points[i], m.Points[(i+1)%len(m.Points)]
+			normal.X += (a.Y - b.Y) * (a.Z + b.Z)
+			normal.Y += (a.Z - b.Z) * (a.X + b.X)
+			normal.Z += (a.X - b.X) * (a.Y + b.Y)
+		}
+	}
+	return PointNormal(v, center, normal)
+}
+
+// Stats samples the region on its own plane every ImagePixelSize mm and
+// summarises the rescaled values of the nearest voxels. Samples falling
+// outside the volume are left out.
+func (m Measurement) Stats(v Volume) RoiStats {
+	var stats RoiStats
+	if !m.IsRegion() || !m.Complete() {
+		return stats
+	}
+	frame := m.Frame(v)
+	outline := m.Outline(frame)
+	if m.Kind == Ellipse {
+		_, major, minor := m.axes()
+		stats.Area = math32.Pi * major.Length() * minor.Length()
+	} else {
+		stats.Area = polygonArea(outline)
+	}
+
+	var corners []*math32.Vector2
+	for i := range outline {
+		corners = append(corners, &outline[i])
+	}
+	box := AABB2f(corners)
+	step := frame.ImagePixelSize.X
+	if step <= 0 {
+		return stats
+	}
+	var sum, sumSq float64
+	stats.Min = math.MaxFloat32
+	stats.Max = -math.MaxFloat32
+	for y := box.Min.Y + step/2; y < box.Max.Y; y += step {
+		for x := box.Min.X + step/2; x < box.Max.X; x += step {
+			if !insidePolygon(outline, x, y) {
+				continue
+			}
+			index, inside := v.VoxelIndex(frame.PlaneToPatient(x, y))
+			if !inside {
+				continue
+			}
+			value := v.Value(index)
+			sum += float64(value)
+			sumSq += float64(value) * float64(value)
+			stats.Min = math32.Min(stats.Min, value)
+			stats.Max = math32.Max(stats.Max, value)
+			stats.Count++
+		}
+	}
+	if stats.Count == 0 {
+		stats.Min, stats.Max = 0, 0
+		return stats
+	}
+	mean := sum / float64(stats.Count)
+	stats.Mean = float32(mean)
+	stats.Std = float32(math.Sqrt(math.Max(0, sumSq/float64(stats.Count)-mean*mean)))
+	return stats
+}
+
+// polygonArea returns the area enclosed by pts with the shoelace formula.
+func polygonArea(pts []math32.Vector2) float32 {
+	var area float32
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		area += pts[i].X*pts[j].Y - pts[j].X*pts[i].Y
+	}
+	return math32.Abs(area) / 2
+}
+
+// insidePolygon reports whether x, y lies inside pts by the even-odd rule.
+func insidePolygon(pts []math32.Vector2, x float32, y float32) bool {
+	inside := false
+	for i, j := 0, len(pts)-1; i < len(pts); j, i = i, i+1 {
+		a, b := pts[i], pts[j]
+		if (a.Y > y) != (b.Y > y) && x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,81 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"awesomeProject/phantom"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestMeasurements(t *testing.T) {
+	p := phantom.New(40, 40, 20, 1)
+	center := p.VoxelCenter(20, 20, 10)
+	p.Shapes = []phantom.Shape{phantom.Sphere{Center: center, Radius: 12, Value: 100}}
+	v := p.Volume()
+	frame := volume.Axial(v, 10)
+
+	at := func(dx float32, dy float32) math32.Vector3 {
+		return *math32.NewVec3().Copy(center).Add(math32.NewVector3(dx, dy, 0))
+	}
+	line := volume.Measurement{Kind: volume.Distance, Points: []math32.Vector3{at(-3, 0), at(3, 4)}}
+	if got := line.Length(); math32.Abs(got-math32.Sqrt(52)) > 1e-4 {
+		t.Errorf("length %v", got)
+	}
+	angle := volume.Measurement{Kind: volume.Angle, Points: []math32.Vector3{at(5, 0), at(0, 0), at(0, 7)}}
+	if got := angle.Degrees(); math32.Abs(got-90) > 1e-3 {
+		t.Errorf("angle %v", got)
+	}
+	if !line.OnPlane(frame, 0.5) || line.OnPlane(volume.Axial(v, 12), 0.5) {
+		t.Errorf("line should show on slice 10 only")
+	}
+
+	square := volume.Measurement{Kind: volume.Freehand, Points: []math32.Vector3{at(-5, -5), at(5, -5), at(5, 5), at(-5, 5)}}
+	stats := square.Stats(v)
+	px := square.Frame(v).ImagePixelSize.X
+	if math32.Abs(stats.Area-100) > 1e-3 || math32.Abs(float32(stats.Count)*px*px-100) > 5 {
+		t.Errorf("square area %v count %v of %v mm pixels", stats.Area, stats.Count, px)
+	}
+	if stats.Mean != 100 || stats.Std != 0 || stats.Min != 100 || stats.Max != 100 {
+		t.Errorf("square inside sphere: %+v", stats)
+	}
+
+	a, b := at(-18, -18), at(18, 18)
+	ellipse := volume.EllipseFromCorners(frame, &a, &b)
+	stats = ellipse.Stats(v)
+	if math32.Abs(stats.Area-math32.Pi*18*18) > 1e-2 {
+		t.Errorf("ellipse area %v", stats.Area)
+	}
+	if stats.Min != -1000 || stats.Max != 100 || stats.Mean <= -1000 || stats.Mean >= 100 {
+		t.Errorf("ellipse across sphere edge: %+v", stats)
+	}
+}
+
+func TestMeasurementJSON(t *testing.T) {
+	want := volume.Measurement{Kind: volume.Freehand, Points: []math32.Vector3{{X: 1, Y: 2, Z: 3}, {X: 4, Y: 5, Z: 6}}}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Freehand"`) {
+		t.Errorf("kind not written by name: %s", data)
+	}
+	var got volume.Measurement
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != want.Kind || len(got.Points) != 2 || got.Points[1] != want.Points[1] {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestInvalidKind(t *testing.T) {
+	if _, err := json.Marshal(volume.Measurement{Kind: volume.MeasureKind(7)}); err == nil {
+		t.Error("wrote an invalid measurement kind")
+	}
+	if name := volume.MeasureKind(-1).String(); name != "MeasureKind(-1)" {
+		t.Errorf("invalid kind named %q", name)
+	}
+}
@@ -0,0 +1,101 @@
+package volume
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteMesh writes mesh to path as binary STL, OBJ or ASCII PLY, following
+// the extension; coordinates are in mm.
+func WriteMesh(path string, mesh Mesh) error {
+	var write func(io.Writer) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".stl":
+		write = mesh.writeSTL
+	case ".obj":
+		write = mesh.writeOBJ
+	case ".ply":
+		write = mesh.writePLY
+	default:
+		return fmt.Errorf("%s: mesh files end in .stl, .obj or .ply", path)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	err = write(w)
+	if ferr := w.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (mesh Mesh) writeSTL(w io.Writer) error {
+	header := make([]byte, 80)
+	copy(header, "isosurface, mm")
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(mesh.Indices)/3)); err != nil {
+		return err
+	}
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		a, b, c := mesh.Indices[i], mesh.Indices[i+1], mesh.Indices[i+2]
+		n := mesh.faceNormal(a, b, c).Normalize()
+		record := []float32{n.X, n.Y, n.Z}
+		for _, j := range []uint32{a, b, c} {
+			p := mesh.Vertices[j]
+			record = append(record, p.X, p.Y, p.Z)
+		}
+		if err := binary.Write(w, binary.LittleEndian, record); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint16(0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mesh Mesh) writeOBJ(w io.Writer) error {
+	for _, p := range mesh.Vertices {
+		if _, err := fmt.Fprintf(w, "v %g %g %g\n", p.X, p.Y, p.Z); err != nil {
+			return err
+		}
+	}
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		// OBJ counts vertices from one.
+		if _, err := fmt.Fprintf(w, "f %d %d %d\n", mesh.Indices[i]+1, mesh.Indices[i+1]+1, mesh.Indices[i+2]+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mesh Mesh) writePLY(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "ply\nformat ascii 1.0\nelement vertex %d\nproperty float x\nproperty float y\nproperty float z\n"+
+		"element face %d\nproperty list uchar int vertex_indices\nend_header\n", len(mesh.Vertices), len(mesh.Indices)/3)
+	if err != nil {
+		return err
+	}
+	for _, p := range mesh.Vertices {
+		if _, err := fmt.Fprintf(w, "%g %g %g\n", p.X, p.Y, p.Z); err != nil {
+			return err
+		}
+	}
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		if _, err := fmt.Fprintf(w, "3 %d %d %d\n", mesh.Indices[i], mesh.Indices[i+1], mesh.Indices[i+2]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
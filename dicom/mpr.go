@@ -2,7 +2,6 @@ package volume
 
 import (
 	"image"
-	"image/color"
 	"image/jpeg"
 	"log"
 	"math"
@@ -54,8 +53,7 @@ func Mpr(slice []byte, width int, height int, data DcmData, debug bool) *image.R
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
 	for c := 0; c < width; c++ {
 		for r := 0; r < height; r++ {
-			p := slice[r*width+c]
-			img.SetRGBA(c, r, color.RGBA{A: 0xFF, R: p, G: p, B: p})
+			img.SetRGBA(c, r, data.color(slice[r*width+c]))
 		}
 	}
 
@@ -0,0 +1,57 @@
+package volume
+
+import (
+	"sort"
+
+	"github.com/g3n/engine/math32"
+)
+
+// obliqueThreshold is the smallest direction cosine that still contributes a
+// letter to an orientation label.
+const obliqueThreshold = 0.25
+
+// EdgeLabels names the patient direction each image edge points towards.
+type EdgeLabels struct {
+	Left   string
+	Right  string
+	Top    string
+	Bottom string
+}
+
+// OrientationLabel returns the anatomical direction of dir in DICOM patient
+// (LPS) space, e.g. "L" for +x or "LPH" for an oblique direction, strongest
+// component first.
+func OrientationLabel(dir *math32.Vector3) string {
+	letters := [3][2]byte{{'L', 'R'}, {'P', 'A'}, {'H', 'F'}}
+	axes := []int{0, 1, 2}
+	sort.SliceStable(axes, func(i, j int) bool {
+		return math32.Abs(dir.Component(axes[i])) > math32.Abs(dir.Component(axes[j]))
+	})
+	length := dir.Length()
+	var label []byte
+	for _, axis := range axes {
+		c := dir.Component(axis)
+		if math32.Abs(c) < obliqueThreshold*length {
+			continue
+		}
+		if c > 0 {
+			label = append(label, letters[axis][0])
+		} else {
+			label = append(label, letters[axis][1])
+		}
+	}
+	return string(label)
+}
+
+// Labels returns the orientation labels of the edges of the reformatted image,
+// whose columns run along the basis x axis and rows along its y axis.
+func (sliceFrame SliceFrame) Labels() EdgeLabels {
+	xDir := math32.NewVector3(1, 0, 0).ApplyMatrix4(sliceFrame.RotatedFrame.Basis)
+	yDir := math32.NewVector3(0, 1, 0).ApplyMatrix4(sliceFrame.RotatedFrame.Basis)
+	return EdgeLabels{
+		Left:   OrientationLabel(math32.NewVec3().Copy(xDir).Negate()),
+		Right:  OrientationLabel(xDir),
+		Top:    OrientationLabel(math32.NewVec3().Copy(yDir).Negate()),
+		Bottom: OrientationLabel(yDir),
+	}
+}
@@ -0,0 +1,35 @@
+package volume
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestOrientationLabel(t *testing.T) {
+	tests := []struct {
+		dir  *math32.Vector3
+		want string
+	}{
+		{math32.NewVector3(1, 0, 0), "L"},
+		{math32.NewVector3(-1, 0, 0), "R"},
+		{math32.NewVector3(0, -1, 0), "A"},
+		{math32.NewVector3(0, 0, -2), "F"},
+		{math32.NewVector3(0.6, 0.5, 0.4), "LPH"},
+		{math32.NewVector3(-0.1, 0.9, -0.4), "PF"},
+	}
+	for _, tt := range tests {
+		if got := OrientationLabel(tt.dir); got != tt.want {
+			t.Errorf("OrientationLabel(%v) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestAxialLabels(t *testing.T) {
+	v := Volume{DcmData: NewDcmData(10, 10, 10, math32.NewMatrix4(), math32.NewVec3(), math32.NewVector3(1, 1, 1))}
+	got := Axial(v, 5).Labels()
+	want := EdgeLabels{Left: "R", Right: "L", Top: "A", Bottom: "P"}
+	if got != want {
+		t.Errorf("Labels() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package volume
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/g3n/engine/math32"
+)
+
+// Projection is how the values along a ray combine into one pixel.
+type Projection int
+
+const (
+	NoProjection Projection = iota
+	// MaxIntensity keeps the brightest value, as for angiography.
+	MaxIntensity
+	// MinIntensity keeps the darkest value, as for airways.
+	MinIntensity
+	// MeanIntensity averages the values, like a radiograph.
+	MeanIntensity
+)
+
+var projectionNames = []string{"none", "mip", "minip", "average"}
+
+func (p Projection) String() string {
+	return enumName(projectionNames, "Projection", int(p))
+}
+
+// ParseProjection reads a projection written as by String.
+func ParseProjection(s string) (Projection, error) {
+	for i, name := range projectionNames {
+		if strings.EqualFold(name, s) {
+			return Projection(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown projection %q", s)
+}
+
+// Project fills the frame's image with the projection of the values met
+// along the plane normal from near to far mm off the plane, sampled every
+// half voxel and windowed like Cut. Pixels whose rays miss the volume are
+// black.
+func (sliceFrame SliceFrame) Project(v Volume, mode Projection, near float32, far float32) {
+	imgWidth := int(sliceFrame.ImageSize.X)
+	imgHeight := int(sliceFrame.ImageSize.Y)
+	toVoxel := math32.NewMatrix4()
+	toVoxel.GetInverse(v.DcmData.Calibration)
+	s := NewSampler(v)
+
+	basis := sliceFrame.RotatedFrame.Basis
+	xDir := math32.NewVector3(1, 0, 0).ApplyMatrix4(basis).Normalize()
+	yDir := math32.NewVector3(0, 1, 0).ApplyMatrix4(basis).Normalize()
+	normal := math32.NewVector3(0, 0, 1).ApplyMatrix4(basis).Normalize()
+	size := v.DcmData.VoxelSize
+	step := math32.Min(size.X, math32.Min(size.Y, size.Z)) / 2
+	samples := int((far-near)/step) + 1
+
+	// Walk the rays in voxel coordinates: a start per pixel and fixed steps.
+	origin := sliceFrame.ImageOrigin().Add(math32.NewVec3().Copy(normal).MultiplyScalar(near)).ApplyMatrix4(toVoxel)
+	zero := math32.NewVec3().ApplyMatrix4(toVoxel)
+	at := func(dir *math32.Vector3, length float32) *math32.Vector3 {
+		return math32.NewVec3().Copy(dir).MultiplyScalar(length).ApplyMatrix4(toVoxel).Sub(zero)
+	}
+	across := at(xDir, sliceFrame.ImagePixelSize.X)
+	down := at(yDir, sliceFrame.ImagePixelSize.Y)
+	along := at(normal, step)
+
+	image := make([]byte, imgWidth*imgHeight)
+	rows := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := range rows {
+				for x := 0; x < imgWidth; x++ {
+					p := math32.NewVec3().Copy(origin).
+						Add(math32.NewVec3().Copy(across).MultiplyScalar(float32(x))).
+						Add(math32.NewVec3().Copy(down).MultiplyScalar(float32(y)))
+					value, hit := float32(0), 0
+					for i := 0; i < samples; i++ {
+						if s.Inside(p.X, p.Y, p.Z) {
+							sample := s.At(p.X, p.Y, p.Z)
+							switch {
+							case hit == 0:
+								value = sample
+							case mode == MaxIntensity && sample > value,
+								mode == MinIntensity && sample < value:
+								value = sample
+							case mode == MeanIntensity:
+								value += sample
+							}
+							hit++
+						}
+						p.Add(along)
+					}
+					if hit == 0 {
+						continue
+					}
+					if mode == MeanIntensity {
+						value /= float32(hit)
+					}
+					image[imgWidth*y+x] = v.DcmData.ToByte(value)
+				}
+			}
+		}()
+	}
+	for y := 0; y < imgHeight; y++ {
+		rows <- y
+	}
+	close(rows)
+	wg.Wait()
+	*sliceFrame.Mpr = Mpr(image, imgWidth, imgHeight, v.DcmData, false)
+}
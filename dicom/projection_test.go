@@ -0,0 +1,48 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"awesomeProject/phantom"
+	"testing"
+)
+
+func TestProjection(t *testing.T) {
+	p := phantom.New(32, 32, 32, 1)
+	center := p.VoxelCenter(16, 16, 24)
+	p.Shapes = []phantom.Shape{phantom.Sphere{Center: center, Radius: 4, Value: 1000}}
+	v := p.Volume()
+	v.SetWindow(0, 2000)
+
+	pixel := func(frame volume.SliceFrame) uint8 {
+		at := frame.PatientToPlane(center)
+		x := int((at.X - frame.Box2f.Min.X) / frame.ImagePixelSize.X)
+		y := int((at.Y - frame.Box2f.Min.Y) / frame.ImagePixelSize.Y)
+		return (*frame.Mpr).RGBAAt(x, y).R
+	}
+	cut := volume.Axial(v, 8)
+	cut.Cut(v)
+	mip := volume.Axial(v, 8)
+	mip.Project(v, volume.MaxIntensity, -40, 40)
+	minip := volume.Axial(v, 8)
+	minip.Project(v, volume.MinIntensity, -40, 40)
+	mean := volume.Axial(v, 8)
+	mean.Project(v, volume.MeanIntensity, -40, 40)
+
+	if pixel(mip) < 250 || pixel(cut) > 5 || pixel(minip) > 5 {
+		t.Errorf("mip %d, cut %d, minip %d", pixel(mip), pixel(cut), pixel(minip))
+	}
+	if m := pixel(mean); m <= pixel(minip) || m >= pixel(mip) {
+		t.Errorf("mean %d", m)
+	}
+}
+
+func TestProjectionNames(t *testing.T) {
+	for _, p := range []volume.Projection{volume.NoProjection, volume.MaxIntensity, volume.MinIntensity, volume.MeanIntensity} {
+		if got, err := volume.ParseProjection(p.String()); err != nil || got != p {
+			t.Errorf("%v read back as %v, %v", p, got, err)
+		}
+	}
+	if name := volume.Projection(9).String(); name != "Projection(9)" {
+		t.Errorf("invalid projection named %q", name)
+	}
+}
@@ -0,0 +1,393 @@
+package volume
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/g3n/engine/math32"
+)
+
+// TransformKind is how a registration may move a volume: rigidly, in 6
+// degrees of freedom, by an affine map adding scales and shears, or by a
+// similarity, rigid but for one scale.
+type TransformKind int
+
+const (
+	Rigid TransformKind = iota
+	Affine
+	Similarity
+)
+
+var transformKindNames = []string{"rigid", "affine", "similarity"}
+
+func (k TransformKind) String() string {
+	return enumName(transformKindNames, "TransformKind", int(k))
+}
+
+// ParseTransformKind reads a kind written as by String, in any case.
+func ParseTransformKind(s string) (TransformKind, error) {
+	for i, name := range transformKindNames {
+		if strings.EqualFold(name, s) {
+			return TransformKind(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown transform %q", s)
+}
+
+// Metric is the similarity a registration maximises: mutual information,
+// normalised so that shrinking the overlap does not pay, for series of
+// different modalities, or normalised cross-correlation, for series whose
+// values match.
+type Metric int
+
+const (
+	MutualInformation Metric = iota
+	CrossCorrelation
+)
+
+var metricNames = []string{"mi", "ncc"}
+
+func (m Metric) String() string {
+	return enumName(metricNames, "Metric", int(m))
+}
+
+// ParseMetric reads a metric written as by String, in any case.
+func ParseMetric(s string) (Metric, error) {
+	for i, name := range metricNames {
+		if strings.EqualFold(name, s) {
+			return Metric(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown metric %q", s)
+}
+
+// Registration estimates the transform aligning a moving volume onto a fixed
+// one, from coarse to fine over a pyramid of volumes each half the size of
+// the next.
+type Registration struct {
+	Kind   TransformKind
+	Metric Metric
+	// Levels is the number of resolutions, 3 when zero.
+	Levels int
+	// Iterations bounds the search's sweeps at each level, 100 when zero.
+	Iterations int
+}
+
+const (
+	// registrationSamples bounds the fixed voxels the metric reads.
+	registrationSamples = 20000
+	// registrationBins is the joint histogram's size along each axis.
+	registrationBins = 32
+	// registrationHalvings is how often the search halves its steps at a
+	// level before moving to the next.
+	registrationHalvings = 6
+	// pyramidMin is the smallest a downsampled volume may get along any axis.
+	pyramidMin = 16
+)
+
+// Register returns the matrix taking moving's patient coordinates to fixed's
+// and the metric reached at full resolution. The search starts from the
+// identity, so the volumes should roughly overlap as loaded.
+func (r Registration) Register(fixed Volume, moving Volume) (*math32.Matrix4, float32, error) {
+	if len(fixed.Values) == 0 || len(moving.Values) == 0 {
+		return nil, 0, fmt.Errorf("nothing to register")
+	}
+	levels, iterations := r.Levels, r.Iterations
+	if levels <= 0 {
+		levels = 3
+	}
+	if iterations <= 0 {
+		iterations = 100
+	}
+	fixeds, movings := pyramid(fixed, levels), pyramid(moving, levels)
+	if len(movings) < len(fixeds) {
+		fixeds = fixeds[:len(movings)]
+	}
+	params := make([]float32, 6)
+	switch r.Kind {
+	case Affine:
+		params = make([]float32, 12)
+	case Similarity:
+		params = make([]float32, 7)
+	}
+	box := fixed.GetCorners().Box
+	center := *box.Center(nil)
+	radius := box.Size(nil).Length() / 2
+	var score float32
+	for l := len(fixeds) - 1; l >= 0; l-- {
+		cost := newRegistrationCost(fixeds[l], movings[l], r.Metric, center)
+		// Steps of two voxels, turning and stretching the edges as far.
+		size := fixeds[l].DcmData.VoxelSize
+		steps := make([]float32, len(params))
+		for i := range steps {
+			steps[i] = 2 * math32.Max(size.X, math32.Max(size.Y, size.Z))
+			if i >= 3 {
+				steps[i] /= radius
+			}
+		}
+		score = cost.search(params, steps, iterations)
+	}
+	toFixed := math32.NewMatrix4()
+	if err := toFixed.GetInverse(fixedToMoving(params, center)); err != nil {
+		return nil, score, err
+	}
+	return toFixed, score, nil
+}
+
+// Transformed returns the volume moved by m, a matrix between patient
+// coordinates such as Register returns. The values stay as they are; only
+// where they lie changes, so everything resampling through the calibration
+// sees the volume moved. The orientation follows the moved voxel axes, made
+// orthonormal again when m shears them.
+func (volume Volume) Transformed(m *math32.Matrix4) Volume {
+	data := volume.DcmData
+	data.Calibration = math32.NewMatrix4().MultiplyMatrices(m, volume.DcmData.Calibration)
+	if data.Origin != nil {
+		data.Origin = math32.NewVec3().Copy(data.Origin).ApplyMatrix4(m)
+	}
+	if data.Orientation != nil {
+		data.Orientation = orientationOf(data.Calibration)
+	}
+	volume.DcmData = data
+	return volume
+}
+
+// orientationOf returns the voxel axes of calibration made orthonormal in
+// order, by Gram-Schmidt, so that a shear leaves a rotation.
+func orientationOf(calibration *math32.Matrix4) *math32.Matrix4 {
+	var axes [3]*math32.Vector3
+	for i := range axes {
+		axes[i] = math32.NewVector3(calibration[4*i], calibration[4*i+1], calibration[4*i+2])
+		for _, done := range axes[:i] {
+			axes[i].Sub(math32.NewVec3().Copy(done).MultiplyScalar(axes[i].Dot(done)))
+		}
+		axes[i].Normalize()
+	}
+	return math32.NewMatrix4().MakeBasis(axes[0], axes[1], axes[2])
+}
+
+// fixedToMoving is the map from fixed to moving patient coordinates params
+// stand for: a translation, rotations about the fixed volume's centre and,
+// for a similarity, a log scale or, for an affine map, log scales and shears.
+func fixedToMoving(params []float32, center math32.Vector3) *math32.Matrix4 {
+	m := math32.NewMatrix4().MakeTranslation(center.X+params[0], center.Y+params[1], center.Z+params[2])
+	m.Multiply(math32.NewMatrix4().MakeRotationFromEuler(&math32.Vector3{X: params[3], Y: params[4], Z: params[5]}))
+	if len(params) == 7 {
+		m.Multiply(math32.NewMatrix4().MakeScale(exp(params[6]), exp(params[6]), exp(params[6])))
+	}
+	if len(params) == 12 {
+		m.Multiply(math32.NewMatrix4().MakeScale(exp(params[6]), exp(params[7]), exp(params[8])))
+		m.Multiply(math32.NewMatrix4().Set(
+			1, params[9], params[10], 0,
+			0, 1, params[11], 0,
+			0, 0, 1, 0,
+			0, 0, 0, 1))
+	}
+	return m.Multiply(math32.NewMatrix4().MakeTranslation(-center.X, -center.Y, -center.Z))
+}
+
+func exp(x float32) float32 {
+	return float32(math.Exp(float64(x)))
+}
+
+// pyramid returns v and up to levels-1 volumes each downsampled from the one
+// before, finest first.
+func pyramid(v Volume, levels int) []Volume {
+	volumes := []Volume{v}
+	for len(volumes) < levels {
+		last := volumes[len(volumes)-1].DcmData
+		if last.Cols < 2*pyramidMin || last.Rows < 2*pyramidMin || last.Depth < 2*pyramidMin {
+			break
+		}
+		volumes = append(volumes, downsample(volumes[len(volumes)-1]))
+	}
+	return volumes
+}
+
+// downsample averages v's voxels two by two along each axis. Only the values
+// and the geometry are kept.
+func downsample(v Volume) Volume {
+	data := v.DcmData
+	data.Cols, data.Rows, data.Depth = (data.Cols+1)/2, (data.Rows+1)/2, (data.Depth+1)/2
+	data.VoxelSize = math32.NewVec3().Copy(v.DcmData.VoxelSize).MultiplyScalar(2)
+	data.Calibration = math32.NewMatrix4().Copy(v.DcmData.Calibration).
+		Multiply(math32.NewMatrix4().MakeTranslation(0.5, 0.5, 0.5)).
+		Multiply(math32.NewMatrix4().MakeScale(2, 2, 2))
+	values := make([][][]float32, data.Depth)
+	for z := range values {
+		values[z] = make([][]float32, data.Rows)
+		for r := range values[z] {
+			values[z][r] = make([]float32, data.Cols)
+			for c := range values[z][r] {
+				var sum float32
+				for i := 0; i < 8; i++ {
+					zz := minInt(2*z+i/4, v.DcmData.Depth-1)
+					rr := minInt(2*r+i/2%2, v.DcmData.Rows-1)
+					cc := minInt(2*c+i%2, v.DcmData.Cols-1)
+					sum += v.Values[zz][rr][cc]
+				}
+				values[z][r][c] = sum / 8
+			}
+		}
+	}
+	return Volume{Values: values, DcmData: data}
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// registrationCost scores how well the moving volume matches the fixed one
+// sampled on a grid of points.
+type registrationCost struct {
+	metric  Metric
+	center  math32.Vector3
+	points  []math32.Vector3
+	values  []float32
+	toVoxel *math32.Matrix4
+	sampler Sampler
+	// The value ranges the joint histogram spans.
+	fixedLow, fixedHigh   float32
+	movingLow, movingHigh float32
+}
+
+func newRegistrationCost(fixed Volume, moving Volume, metric Metric, center math32.Vector3) registrationCost {
+	c := registrationCost{metric: metric, center: center, toVoxel: math32.NewMatrix4(), sampler: NewSampler(moving)}
+	c.toVoxel.GetInverse(moving.DcmData.Calibration)
+	data := fixed.DcmData
+	stride := int(math.Ceil(math.Cbrt(float64(data.Cols*data.Rows*data.Depth) / registrationSamples)))
+	for z := 0; z < data.Depth; z += stride {
+		for r := 0; r < data.Rows; r += stride {
+			for col := 0; col < data.Cols; col += stride {
+				p := math32.Vector3{X: float32(col), Y: float32(r), Z: float32(z)}
+				c.points = append(c.points, *p.ApplyMatrix4(data.Calibration))
+				c.values = append(c.values, fixed.Values[z][r][col])
+			}
+		}
+	}
+	c.fixedLow, c.fixedHigh = valueRange(fixed)
+	c.movingLow, c.movingHigh = valueRange(moving)
+	return c
+}
+
+func valueRange(v Volume) (float32, float32) {
+	low, high := float32(math.MaxFloat32), float32(-math.MaxFloat32)
+	for _, slice := range v.Values {
+		for _, row := range slice {
+			for _, value := range row {
+				low, high = math32.Min(low, value), math32.Max(high, value)
+			}
+		}
+	}
+	return low, math32.Max(high, low+1)
+}
+
+// score is the metric with the moving volume placed by params, or -Inf when
+// less than a quarter of the samples overlap it.
+func (c registrationCost) score(params []float32) float32 {
+	m := math32.NewMatrix4().MultiplyMatrices(c.toVoxel, fixedToMoving(params, c.center))
+	var fixed, moving []float32
+	for i, p := range c.points {
+		q := p.ApplyMatrix4(m)
+		if c.sampler.Inside(q.X, q.Y, q.Z) {
+			fixed = append(fixed, c.values[i])
+			moving = append(moving, c.sampler.At(q.X, q.Y, q.Z))
+		}
+	}
+	if len(fixed) == 0 || len(fixed) < len(c.points)/4 {
+		return float32(math.Inf(-1))
+	}
+	if c.metric == CrossCorrelation {
+		return crossCorrelation(fixed, moving)
+	}
+	return normalizedMutualInformation(fixed, moving, c.fixedLow, c.fixedHigh, c.movingLow, c.movingHigh)
+}
+
+func crossCorrelation(a []float32, b []float32) float32 {
+	var sa, sb, saa, sbb, sab float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		sa, sb, saa, sbb, sab = sa+x, sb+y, saa+x*x, sbb+y*y, sab+x*y
+	}
+	n := float64(len(a))
+	cov := sab - sa*sb/n
+	variance := (saa - sa*sa/n) * (sbb - sb*sb/n)
+	if variance <= 0 {
+		return 0
+	}
+	return float32(cov / math.Sqrt(variance))
+}
+
+// normalizedMutualInformation is the entropies of a and b over their joint
+// entropy, from 1 for unrelated values to 2 for values that determine each
+// other.
+func normalizedMutualInformation(a []float32, b []float32, aLow float32, aHigh float32, bLow float32, bHigh float32) float32 {
+	bin := func(value float32, low float32, high float32) int {
+		i := int((value - low) / (high - low) * registrationBins)
+		if i < 0 {
+			return 0
+		}
+		if i >= registrationBins {
+			return registrationBins - 1
+		}
+		return i
+	}
+	var joint [registrationBins][registrationBins]float64
+	var ha, hb [registrationBins]float64
+	for i := range a {
+		x, y := bin(a[i], aLow, aHigh), bin(b[i], bLow, bHigh)
+		joint[x][y]++
+		ha[x]++
+		hb[y]++
+	}
+	n := float64(len(a))
+	entropy := func(counts []float64) float64 {
+		var h float64
+		for _, count := range counts {
+			if count > 0 {
+				h -= count / n * math.Log(count/n)
+			}
+		}
+		return h
+	}
+	var hj float64
+	for x := range joint {
+		hj += entropy(joint[x][:])
+	}
+	if hj == 0 {
+		return 0
+	}
+	return float32((entropy(ha[:]) + entropy(hb[:])) / hj)
+}
+
+// search climbs the score from params, moving one parameter at a time by its
+// step and halving the steps when no move helps, and returns the best score.
+// Params are left at the best place found.
+func (c registrationCost) search(params []float32, steps []float32, iterations int) float32 {
+	best := c.score(params)
+	halvings := 0
+	for it := 0; it < iterations && halvings < registrationHalvings; it++ {
+		improved := false
+		for i := range params {
+			for _, sign := range []float32{1, -1} {
+				params[i] += sign * steps[i]
+				if s := c.score(params); s > best {
+					best, improved = s, true
+					break
+				}
+				params[i] -= sign * steps[i]
+			}
+		}
+		if !improved {
+			for i := range steps {
+				steps[i] /= 2
+			}
+			halvings++
+		}
+	}
+	return best
+}
@@ -0,0 +1,152 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"awesomeProject/phantom"
+	"path/filepath"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+// shiftedPhantoms returns a phantom and the same objects moved by shift.
+func shiftedPhantoms(shift *math32.Vector3) (volume.Volume, volume.Volume) {
+	shapes := func(offset *math32.Vector3) []phantom.Shape {
+		at := func(x float32, y float32, z float32) *math32.Vector3 {
+			return math32.NewVector3(x, y, z).Add(offset)
+		}
+		return []phantom.Shape{
+			phantom.Sphere{Center: at(40, 40, 40), Radius: 22, Value: 40},
+			phantom.Sphere{Center: at(30, 44, 36), Radius: 8, Value: 400},
+			phantom.Sphere{Center: at(52, 34, 48), Radius: 6, Value: -300},
+		}
+	}
+	fixed := phantom.New(40, 40, 40, 2, shapes(math32.NewVec3())...)
+	moving := phantom.New(40, 40, 40, 2, shapes(shift)...)
+	return fixed.Volume(), moving.Volume()
+}
+
+func TestRegister(t *testing.T) {
+	shift := math32.NewVector3(6, -4, 2)
+	fixed, moving := shiftedPhantoms(shift)
+	for _, r := range []volume.Registration{
+		{Kind: volume.Rigid, Metric: volume.CrossCorrelation},
+		{Kind: volume.Rigid, Metric: volume.MutualInformation},
+		{Kind: volume.Affine, Metric: volume.CrossCorrelation},
+	} {
+		m, score, err := r.Register(fixed, moving)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Where a moving point lands is where it was before the shift.
+		p := math32.NewVector3(30, 44, 36)
+		got := math32.NewVec3().Copy(p).Add(shift).ApplyMatrix4(m)
+		if d := got.DistanceTo(p); d > 1 {
+			t.Errorf("%s %s: %v lands at %v, %.2f mm off, score %v", r.Kind, r.Metric, p, got, d, score)
+		}
+		moved := moving.Transformed(m)
+		if index, inside := moved.VoxelIndex(p); !inside || moved.Value(index) != 400 {
+			t.Errorf("%s %s: registered moving volume reads %v at %v", r.Kind, r.Metric, moved.Value(index), p)
+		}
+	}
+}
+
+func TestRegistrationFiles(t *testing.T) {
+	fixed, moving := shiftedPhantoms(math32.NewVec3())
+	m := math32.NewMatrix4().MakeRotationZ(0.1).SetPosition(math32.NewVector3(1, -2, 3.5))
+	for _, name := range []string{"reg.txt", "reg.dcm"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := volume.WriteRegistration(path, fixed, moving, m, volume.Rigid); err != nil {
+			t.Fatal(err)
+		}
+		read, err := volume.ReadRegistration(path, moving)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range m {
+			if math32.Abs(read[i]-m[i]) > 1e-5 {
+				t.Fatalf("%s: read %v, wrote %v", name, read, m)
+			}
+		}
+	}
+
+	// A DICOM registration is looked up by the series' frames of reference,
+	// so it cannot hold two series sharing one.
+	dir := t.TempDir()
+	series := func(name string) volume.Volume {
+		if err := phantom.New(8, 8, 4, 2).WriteSeries(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+		return volume.New(filepath.Join(dir, name))
+	}
+	same := func(a *math32.Matrix4, b *math32.Matrix4) bool {
+		for i := range a {
+			if math32.Abs(a[i]-b[i]) > 1e-5 {
+				return false
+			}
+		}
+		return true
+	}
+	fixed, moving = series("fixed"), series("moving")
+	path := filepath.Join(dir, "reg.dcm")
+	if err := volume.WriteRegistration(path, fixed, moving, m, volume.Rigid); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := volume.ReadRegistration(path, moving); err != nil || !same(read, m) {
+		t.Errorf("moving series read %v, %v, want %v", read, err, m)
+	}
+	if read, err := volume.ReadRegistration(path, fixed); err != nil || !same(read, math32.NewMatrix4()) {
+		t.Errorf("fixed series read %v, %v, want the identity", read, err)
+	}
+	if _, err := volume.ReadRegistration(path, series("other")); err == nil {
+		t.Error("read a registration for another series")
+	}
+	if err := volume.WriteRegistration(path, fixed, fixed, m, volume.Rigid); err == nil {
+		t.Error("wrote a registration between series sharing a frame of reference")
+	}
+}
+
+func TestRegistrationNames(t *testing.T) {
+	for _, kind := range []volume.TransformKind{volume.Rigid, volume.Affine, volume.Similarity} {
+		if got, err := volume.ParseTransformKind(kind.String()); err != nil || got != kind {
+			t.Errorf("%v read back as %v, %v", kind, got, err)
+		}
+	}
+	for _, m := range []volume.Metric{volume.MutualInformation, volume.CrossCorrelation} {
+		if got, err := volume.ParseMetric(m.String()); err != nil || got != m {
+			t.Errorf("%v read back as %v, %v", m, got, err)
+		}
+	}
+	if kind, m := volume.TransformKind(9).String(), volume.Metric(9).String(); kind != "TransformKind(9)" || m != "Metric(9)" {
+		t.Errorf("invalid kind named %q, metric %q", kind, m)
+	}
+}
+
+func TestTransformedOrientation(t *testing.T) {
+	_, moving := shiftedPhantoms(math32.NewVec3())
+	shear := math32.NewMatrix4().Set(
+		1, 0.3, 0, 5,
+		0, 1, 0.2, 0,
+		0.1, 0, 1.5, -2,
+		0, 0, 0, 1)
+	moved := moving.Transformed(shear)
+	o, c := moved.DcmData.Orientation, moved.DcmData.Calibration
+	axis := func(m *math32.Matrix4, i int) *math32.Vector3 {
+		return math32.NewVector3(m[4*i], m[4*i+1], m[4*i+2])
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			want := float32(0)
+			if i == j {
+				want = 1
+			}
+			if d := axis(o, i).Dot(axis(o, j)); math32.Abs(d-want) > 1e-5 {
+				t.Errorf("orientation axes %d and %d have dot product %v", i, j, d)
+			}
+		}
+	}
+	// The first axis keeps the direction of the moved columns.
+	if d := axis(o, 0).Dot(axis(c, 0).Normalize()); d < 1-1e-5 {
+		t.Errorf("orientation turned the column axis, dot product %v", d)
+	}
+}
@@ -0,0 +1,233 @@
+package volume
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/g3n/engine/math32"
+	"github.com/suyashkumar/dicom"
+	"github.com/suyashkumar/dicom/pkg/tag"
+	"github.com/suyashkumar/dicom/pkg/uid"
+)
+
+// spatialRegistration is the SOP class of rigid and affine registrations.
+const spatialRegistration = "1.2.840.10008.5.1.4.1.1.66.1"
+
+// WriteRegistration saves m, taking moving's patient coordinates to fixed's,
+// to path: a DICOM Spatial Registration object for a .dcm, a text matrix of
+// four rows otherwise. The kind is recorded in the DICOM matrix type. A
+// DICOM registration tells the series apart by frame of reference, so it is
+// refused for series sharing one.
+func WriteRegistration(path string, fixed Volume, moving Volume, m *math32.Matrix4, kind TransformKind) error {
+	if strings.ToLower(filepath.Ext(path)) == ".dcm" {
+		return writeSpatialRegistration(path, fixed, moving, m, kind)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "# %s registration, moving to fixed patient coordinates\n", kind)
+	for row := 0; row < 4; row++ {
+		fmt.Fprintf(w, "%g %g %g %g\n", m[row], m[4+row], m[8+row], m[12+row])
+	}
+	err = w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeSpatialRegistration(path string, fixed Volume, moving Volume, m *math32.Matrix4, kind TransformKind) error {
+	var source dicom.Dataset
+	if len(fixed.Dicoms) > 0 {
+		source = fixed.Dicoms[0].dataset
+	}
+	if frame := frameOfReference(fixed); frame != "" && frame == frameOfReference(moving) {
+		return fmt.Errorf("%s: fixed and moving series share frame of reference %s; save the registration as text", path, frame)
+	}
+	uids := map[string]string{
+		"study":  stringOf(source, tag.StudyInstanceUID),
+		"fixed":  frameOfReference(fixed),
+		"moving": frameOfReference(moving),
+		"series": "",
+		"sop":    "",
+	}
+	for name, id := range uids {
+		if id == "" {
+			var err error
+			if uids[name], err = newUID(); err != nil {
+				return err
+			}
+		}
+	}
+	matrixType := map[TransformKind]string{Rigid: "RIGID", Affine: "AFFINE", Similarity: "RIGID_SCALE"}[kind]
+	// One item per frame of reference, each with the matrix taking it to the
+	// fixed one.
+	var registrations [][]*dicom.Element
+	for _, frame := range []struct {
+		uid    string
+		matrix *math32.Matrix4
+	}{{uids["fixed"], math32.NewMatrix4()}, {uids["moving"], m}} {
+		var values []string
+		for row := 0; row < 4; row++ {
+			for col := 0; col < 4; col++ {
+				values = append(values, strconv.FormatFloat(float64(frame.matrix[4*col+row]), 'g', 8, 32))
+			}
+		}
+		matrix := &elements{}
+		matrix.add(tag.FrameOfReferenceTransformationMatrix, values)
+		matrix.add(tag.FrameOfReferenceTransformationMatrixType, []string{matrixType})
+		registration := &elements{}
+		registration.add(tag.MatrixSequence, [][]*dicom.Element{matrix.list})
+		item := &elements{}
+		item.add(tag.FrameOfReferenceUID, []string{frame.uid})
+		item.add(tag.MatrixRegistrationSequence, [][]*dicom.Element{registration.list})
+		for _, e := range []*elements{matrix, registration, item} {
+			if e.err != nil {
+				return e.err
+			}
+		}
+		registrations = append(registrations, item.list)
+	}
+
+	now := time.Now()
+	ds := &elements{}
+	ds.add(tag.MediaStorageSOPClassUID, []string{spatialRegistration})
+	ds.add(tag.MediaStorageSOPInstanceUID, []string{uids["sop"]})
+	ds.add(tag.TransferSyntaxUID, []string{uid.ExplicitVRLittleEndian})
+	ds.add(tag.SOPClassUID, []string{spatialRegistration})
+	ds.add(tag.SOPInstanceUID, []string{uids["sop"]})
+	ds.add(tag.Modality, []string{"REG"})
+	ds.add(tag.PatientName, []string{stringOf(source, tag.PatientName)})
+	ds.add(tag.PatientID, []string{stringOf(source, tag.PatientID)})
+	ds.add(tag.StudyInstanceUID, []string{uids["study"]})
+	ds.add(tag.SeriesInstanceUID, []string{uids["series"]})
+	ds.add(tag.SeriesNumber, []string{"1001"})
+	ds.add(tag.InstanceNumber, []string{"1"})
+	ds.add(tag.FrameOfReferenceUID, []string{uids["fixed"]})
+	ds.add(tag.ContentDate, []string{now.Format("20060102")})
+	ds.add(tag.ContentTime, []string{now.Format("150405")})
+	ds.add(tag.ContentLabel, []string{"REGISTRATION"})
+	ds.add(tag.RegistrationSequence, registrations)
+	if ds.err != nil {
+		return ds.err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = dicom.Write(f, dicom.Dataset{Elements: ds.list})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// frameOfReference returns the frame of reference UID of v's series, empty
+// when it was not read from DICOM files.
+func frameOfReference(v Volume) string {
+	if len(v.Dicoms) == 0 {
+		return ""
+	}
+	return stringOf(v.Dicoms[0].dataset, tag.FrameOfReferenceUID)
+}
+
+// ReadRegistration loads a matrix saved by WriteRegistration for moving. Of
+// a Spatial Registration object's items, the one for moving's frame of
+// reference is taken, or when moving has none, the one item for a frame
+// other than the registration's own.
+func ReadRegistration(path string, moving Volume) (*math32.Matrix4, error) {
+	if ds, err := dicom.ParseFile(path, nil); err == nil {
+		m, err := spatialRegistrationMatrix(ds, frameOfReference(moving))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values []float32
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, field := range strings.Fields(line) {
+			f, err := strconv.ParseFloat(field, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			values = append(values, float32(f))
+		}
+	}
+	return matrixOf(values)
+}
+
+// spatialRegistrationMatrix returns the matrix of ds's item for frame, or
+// when frame is empty, of its one item for another frame than its own. The
+// matrices of the item apply in turn.
+func spatialRegistrationMatrix(ds dicom.Dataset, frame string) (*math32.Matrix4, error) {
+	registrations, err := ds.FindElementByTag(tag.RegistrationSequence)
+	if err != nil {
+		return nil, fmt.Errorf("not a spatial registration")
+	}
+	itemsOf := func(item []*dicom.Element, t tag.Tag) [][]*dicom.Element {
+		if e := find(item, t); e != nil {
+			return sequenceItems(e)
+		}
+		return nil
+	}
+	own := stringOf(ds, tag.FrameOfReferenceUID)
+	var found [][]*dicom.Element
+	for _, item := range sequenceItems(registrations) {
+		id := ""
+		if e := find(item, tag.FrameOfReferenceUID); e != nil {
+			id = stringValue(e)
+		}
+		if (frame != "" && id == frame) || (frame == "" && id != own) {
+			found = append(found, item)
+		}
+	}
+	switch {
+	case len(found) == 0 && frame != "":
+		return nil, fmt.Errorf("no registration for frame of reference %s", frame)
+	case len(found) != 1 && frame == "":
+		return nil, fmt.Errorf("%d registrations to choose from for a series without a frame of reference", len(found))
+	}
+	m := math32.NewMatrix4()
+	for _, registration := range itemsOf(found[0], tag.MatrixRegistrationSequence) {
+		for _, matrix := range itemsOf(registration, tag.MatrixSequence) {
+			e := find(matrix, tag.FrameOfReferenceTransformationMatrix)
+			if e == nil {
+				continue
+			}
+			strs, _ := e.Value.GetValue().([]string)
+			var values []float32
+			for _, s := range strs {
+				values = append(values, readFloat(s))
+			}
+			next, err := matrixOf(values)
+			if err != nil {
+				return nil, err
+			}
+			m.MultiplyMatrices(next, m)
+		}
+	}
+	return m, nil
+}
+
+// matrixOf builds a matrix from its 16 values row by row.
+func matrixOf(values []float32) (*math32.Matrix4, error) {
+	if len(values) != 16 {
+		return nil, fmt.Errorf("a matrix has 16 values, not %d", len(values))
+	}
+	v := values
+	return math32.NewMatrix4().Set(v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7], v[8], v[9], v[10], v[11], v[12], v[13], v[14], v[15]), nil
+}
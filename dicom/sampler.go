@@ -0,0 +1,63 @@
+package volume
+
+import "github.com/g3n/engine/math32"
+
+// Sampler reads a volume's values between voxel centres by trilinear
+// interpolation, in voxel coordinates (col, row, slice). Positions past the
+// edges read the edge voxels.
+type Sampler struct {
+	values  [][][]float32
+	max     [3]int
+	spacing math32.Vector3
+}
+
+func NewSampler(v Volume) Sampler {
+	return Sampler{
+		values:  v.Values,
+		max:     [3]int{v.DcmData.Cols - 1, v.DcmData.Rows - 1, v.DcmData.Depth - 1},
+		spacing: *v.DcmData.VoxelSize,
+	}
+}
+
+// Inside reports whether x, y, z lies within the voxel centres' box.
+func (s Sampler) Inside(x float32, y float32, z float32) bool {
+	return x >= 0 && y >= 0 && z >= 0 &&
+		x <= float32(s.max[0]) && y <= float32(s.max[1]) && z <= float32(s.max[2])
+}
+
+// Max returns the highest voxel index along each axis.
+func (s Sampler) Max() [3]int {
+	return s.max
+}
+
+func (s Sampler) At(x float32, y float32, z float32) float32 {
+	clampIndex := func(f float32, max int) (int, int, float32) {
+		if f <= 0 {
+			return 0, 0, 0
+		}
+		i := int(f)
+		if i >= max {
+			return max, max, 0
+		}
+		return i, i + 1, f - float32(i)
+	}
+	x0, x1, fx := clampIndex(x, s.max[0])
+	y0, y1, fy := clampIndex(y, s.max[1])
+	z0, z1, fz := clampIndex(z, s.max[2])
+	lerp := func(a float32, b float32, t float32) float32 { return a + (b-a)*t }
+	p0, p1 := s.values[z0], s.values[z1]
+	c00 := lerp(p0[y0][x0], p0[y0][x1], fx)
+	c01 := lerp(p0[y1][x0], p0[y1][x1], fx)
+	c10 := lerp(p1[y0][x0], p1[y0][x1], fx)
+	c11 := lerp(p1[y1][x0], p1[y1][x1], fx)
+	return lerp(lerp(c00, c01, fy), lerp(c10, c11, fy), fz)
+}
+
+// Gradient is the value gradient at x, y, z along the voxel axes, per mm.
+func (s Sampler) Gradient(x float32, y float32, z float32) math32.Vector3 {
+	return math32.Vector3{
+		X: (s.At(x+1, y, z) - s.At(x-1, y, z)) / (2 * s.spacing.X),
+		Y: (s.At(x, y+1, z) - s.At(x, y-1, z)) / (2 * s.spacing.Y),
+		Z: (s.At(x, y, z+1) - s.At(x, y, z-1)) / (2 * s.spacing.Z),
+	}
+}
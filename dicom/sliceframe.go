@@ -1,8 +1,8 @@
 package volume
 
 import (
+	"fmt"
 	"image"
-	"math"
 
 	"github.com/g3n/engine/math32"
 )
@@ -24,129 +24,111 @@ type AABB struct {
 	Box               *math32.Box3
 }
 
-func filter(vecs []math32.Vector3) []math32.Vector3 {
-
-	var acc []math32.Vector3
-	acc = append(acc, vecs[0])
-	for i := 0; i < len(vecs); i++ {
-
-		for j := 0; j < len(acc); j++ {
-			if vecs[i].Equals(&acc[j]) {
-				break
-			}
-			if j == len(acc)-1 {
-				acc = append(acc, vecs[i])
-			}
-		}
-	}
-	return acc
+// axes returns the patient directions of the volume's column, row and slice
+// index axes.
+func axes(v Volume) (*math32.Vector3, *math32.Vector3, *math32.Vector3) {
+	dirX := math32.NewVector3(1, 0, 0).ApplyMatrix4(v.DcmData.Orientation).Normalize()
+	dirY := math32.NewVector3(0, 1, 0).ApplyMatrix4(v.DcmData.Orientation).Normalize()
+	dirZ := math32.NewVector3(0, 0, 1).ApplyMatrix4(v.DcmData.Orientation).Normalize()
+	return dirX, dirY, dirZ
 }
 
+// Axial cuts the volume along its own slices, at slice index slice.
 func Axial(v Volume, slice int) SliceFrame {
 
 	origin := math32.NewVector3(0, 0, float32(slice))
 	origin.ApplyMatrix4(v.DcmData.Calibration)
 
-	basis := math32.NewMatrix4()
-	zP := math32.NewVector3(0, 0, -1)
-	return MakeSliceFrame(zP, origin, basis, v)
+	dirX, dirY, dirZ := axes(v)
+	basis := math32.NewMatrix4().MakeBasis(dirX, dirY, dirZ)
+	return MakeSliceFrame(origin, basis, v)
 }
 
+// Coronal cuts the volume across its rows, at row index slice, with the
+// slice axis pointing down the image.
 func Coronal(v Volume, slice int) SliceFrame {
 
-	s := math32.NewVector3(0, float32(slice), 0)
-	s.ApplyMatrix4(v.DcmData.Calibration)
-
-	origin := math32.NewVector3(s.X, s.Y, s.Z)
-	basis := math32.NewMatrix4().MakeRotationX(math.Pi / 2)
-
-	z := math32.NewVector3(0, -1, 0)
+	origin := math32.NewVector3(0, float32(slice), 0)
+	origin.ApplyMatrix4(v.DcmData.Calibration)
 
-	return MakeSliceFrame(z, origin, basis, v)
+	dirX, dirY, dirZ := axes(v)
+	basis := math32.NewMatrix4().MakeBasis(dirX, dirZ.Negate(), dirY)
+	return MakeSliceFrame(origin, basis, v)
 }
 
+// Sagittal cuts the volume across its columns, at column index slice, with
+// the row axis across and the slice axis pointing down the image.
 func Sagittal(v Volume, slice int) SliceFrame {
 
-	basis := math32.NewMatrix4().Multiply(math32.NewMatrix4().MakeRotationY(-math.Pi / 2))
-
-	z := math32.NewVector3(-1, 0, 0)
-	s := math32.NewVector3(float32(slice), 0, 0)
-	s.ApplyMatrix4(v.DcmData.Calibration)
-	origin := math32.NewVector3(s.X, s.Y, s.Z)
+	origin := math32.NewVector3(float32(slice), 0, 0)
+	origin.ApplyMatrix4(v.DcmData.Calibration)
 
-	return MakeSliceFrame(z, origin, basis, v)
+	dirX, dirY, dirZ := axes(v)
+	basis := math32.NewMatrix4().MakeBasis(dirY, dirZ.Negate(), dirX.Negate())
+	return MakeSliceFrame(origin, basis, v)
 }
-func MakeSliceFrame(zP *math32.Vector3, origin *math32.Vector3, basis *math32.Matrix4, v Volume) SliceFrame {
-	aabb := v.GetCorners()
-	var intersections []math32.Vector3
-	var rays []math32.Ray
 
-	p := math32.NewPlane(zP, origin.Length())
-	for _, ray := range getSides(aabb.Box, v) {
-		rays = append(rays, *ray)
-		pt, err := rp(ray, p, aabb)
-		if err == nil {
-			intersections = append(intersections, pt)
-		}
+// PointNormal cuts the volume with the plane through point perpendicular to
+// normal. The image x axis follows the volume's column axis as closely as the
+// plane allows.
+func PointNormal(v Volume, point *math32.Vector3, normal *math32.Vector3) SliceFrame {
+	n := math32.NewVec3().Copy(normal).Normalize()
+	dirX, dirY, _ := axes(v)
+	x := dirX.Sub(math32.NewVec3().Copy(n).MultiplyScalar(dirX.Dot(n)))
+	if x.Length() < 1e-3 {
+		x = dirY.Sub(math32.NewVec3().Copy(n).MultiplyScalar(dirY.Dot(n)))
 	}
+	x.Normalize()
+	y := math32.NewVec3().CrossVectors(n, x)
+	basis := math32.NewMatrix4().MakeBasis(x, y, n)
+	return MakeSliceFrame(math32.NewVec3().Copy(point), basis, v)
+}
 
-	intersections = filter(intersections)
-	box2f := AABB2f(ToPlaneUV(intersections, zP, origin, basis))
-
-	imgWidth := float32(256)
-	boxw := box2f.GetWidth()
-	boxh := box2f.GetHeigth()
-	pixelSize := boxw / float32(imgWidth)
-	imgHeight := boxh / pixelSize
-	imageSize := math32.NewVector2(imgWidth, imgHeight)
-	imageSizeInMm := math32.NewVector2(boxw, boxh)
-	imagePixelSize := math32.NewVector2(pixelSize, pixelSize)
-	mpr := &image.RGBA{}
-	rotatedFrame := RotatedFrame{basis, origin, p}
-	return SliceFrame{
-		rotatedFrame,
-		aabb,
-		box2f,
-		intersections,
-		rays,
-		imageSize,
-		imageSizeInMm,
-		imagePixelSize,
-		&mpr,
+// ThreePoints cuts the volume with the plane through a, b and c, with the
+// image x axis running from a to b and c on the positive y side. Points on a
+// line, or falling together, give no plane.
+func ThreePoints(v Volume, a *math32.Vector3, b *math32.Vector3, c *math32.Vector3) (SliceFrame, error) {
+	ab := math32.NewVec3().SubVectors(b, a)
+	ac := math32.NewVec3().SubVectors(c, a)
+	n := math32.NewVec3().CrossVectors(ab, ac)
+	if n.Length() <= 1e-6*ab.Length()*ac.Length() {
+		return SliceFrame{}, fmt.Errorf("points lie on a line")
 	}
+	n.Normalize()
+	x := ab.Normalize()
+	y := math32.NewVec3().CrossVectors(n, x)
+	basis := math32.NewMatrix4().MakeBasis(x, y, n)
+	return MakeSliceFrame(math32.NewVec3().Copy(a), basis, v), nil
 }
-func FreeRotation(v Volume, basis *math32.Matrix4) SliceFrame {
-	var intersections []math32.Vector3
-	var rays []math32.Ray
 
+// MakeSliceFrame cuts the volume with the plane through origin spanned by the
+// x and y axes of basis; the basis z axis is the plane normal.
+func MakeSliceFrame(origin *math32.Vector3, basis *math32.Matrix4, v Volume) SliceFrame {
 	aabb := v.GetCorners()
-	boxCenter := aabb.Box.Center(nil)
-	basisOrigin := boxCenter
-	z := math32.Vector3{0, 0, -1}
-	z.ApplyMatrix4(basis)
-	z.Normalize()
-
-	plane := math32.NewPlane(&z, basisOrigin.Length())
-	for _, ray := range getSides(aabb.Box, v) {
+	var rays []math32.Ray
+
+	normal := math32.NewVector3(0, 0, 1).ApplyMatrix4(basis).Normalize()
+	p := math32.NewPlane(nil, 0).SetFromNormalAndCoplanarPoint(normal, origin)
+	for _, ray := range getSides(aabb) {
 		rays = append(rays, *ray)
-		pt, err := rp(ray, plane, aabb)
-		if err == nil {
-			intersections = append(intersections, pt)
-		}
 	}
 
-	box2f := AABB2f(ToPlaneUV(intersections, &z, basisOrigin, basis))
+	intersections := PlanePolygon(aabb.CalibratedCorners, p)
+	box2f := AABB2f(ToPlaneUV(intersections, normal, origin, basis))
 
 	imgWidth := float32(256)
 	boxw := box2f.GetWidth()
 	boxh := box2f.GetHeigth()
 	pixelSize := boxw / float32(imgWidth)
-	imgHeight := boxh / pixelSize
+	imgHeight := float32(0)
+	if pixelSize > 0 {
+		imgHeight = boxh / pixelSize
+	}
 	imageSize := math32.NewVector2(imgWidth, imgHeight)
 	imageSizeInMm := math32.NewVector2(boxw, boxh)
 	imagePixelSize := math32.NewVector2(pixelSize, pixelSize)
 	mpr := &image.RGBA{}
-	rotatedFrame := RotatedFrame{basis, basisOrigin, plane}
+	rotatedFrame := RotatedFrame{basis, origin, p}
 	return SliceFrame{
 		rotatedFrame,
 		aabb,
@@ -160,12 +142,42 @@ func FreeRotation(v Volume, basis *math32.Matrix4) SliceFrame {
 	}
 }
 
+// FreeRotation cuts the volume through its center with the plane of basis.
+func FreeRotation(v Volume, basis *math32.Matrix4) SliceFrame {
+	boxCenter := v.GetCorners().Box.Center(nil)
+	return MakeSliceFrame(boxCenter, basis, v)
+}
+
 type RotatedFrame struct {
 	Basis  *math32.Matrix4
 	Origin *math32.Vector3
 	Plane  *math32.Plane
 }
 
+// ImageOrigin returns the patient coordinate of the top left pixel of the
+// reformatted image, the Box2f minimum on the plane.
+func (sliceFrame SliceFrame) ImageOrigin() *math32.Vector3 {
+	return sliceFrame.PlaneToPatient(sliceFrame.Box2f.Min.X, sliceFrame.Box2f.Min.Y)
+}
+
+// PlaneToPatient returns the patient coordinate of the point u, v mm along the
+// basis x and y axes from the frame origin.
+func (sliceFrame SliceFrame) PlaneToPatient(u float32, v float32) *math32.Vector3 {
+	xDir := math32.NewVector3(1, 0, 0).ApplyMatrix4(sliceFrame.RotatedFrame.Basis).Normalize()
+	yDir := math32.NewVector3(0, 1, 0).ApplyMatrix4(sliceFrame.RotatedFrame.Basis).Normalize()
+	return math32.NewVec3().Copy(sliceFrame.RotatedFrame.Origin).
+		Add(xDir.MultiplyScalar(u)).
+		Add(yDir.MultiplyScalar(v))
+}
+
+// PixelToPatient returns the patient coordinate of image pixel x, y; pixel
+// centres sit on integer coordinates.
+func (sliceFrame SliceFrame) PixelToPatient(x float32, y float32) *math32.Vector3 {
+	return sliceFrame.PlaneToPatient(
+		sliceFrame.Box2f.Min.X+x*sliceFrame.ImagePixelSize.X,
+		sliceFrame.Box2f.Min.Y+y*sliceFrame.ImagePixelSize.Y)
+}
+
 func (sliceFrame SliceFrame) Cut(v Volume) {
 	imgWidth := int(sliceFrame.ImageSize.X)
 	imgHeight := int(sliceFrame.ImageSize.Y)
@@ -181,6 +193,7 @@ func (sliceFrame SliceFrame) Cut(v Volume) {
 	xDir := math32.NewVector3(1, 0, 0)
 	xDir.ApplyMatrix4(sliceFrame.RotatedFrame.Basis)
 	xDir.Normalize()
+	imageOrigin := sliceFrame.ImageOrigin()
 
 	for x := 0; x < imgWidth; x++ {
 		for y := 0; y < imgHeight; y++ {
@@ -189,7 +202,7 @@ func (sliceFrame SliceFrame) Cut(v Volume) {
 			fy := float32(y)
 			destX := math32.NewVec3().Copy(xDir).MultiplyScalar(sliceFrame.ImagePixelSize.X * fx)
 			destY := math32.NewVec3().Copy(yDir).MultiplyScalar(sliceFrame.ImagePixelSize.Y * fy)
-			dcmCoords := math32.NewVec3().Add(destY).Add(destX).Add(sliceFrame.RotatedFrame.Origin)
+			dcmCoords := math32.NewVec3().Add(destY).Add(destX).Add(imageOrigin)
 			dcmCoords.ApplyMatrix4(calibratedToVoXel)
 
 			vX := clamp(dcmCoords.X, 0, v.DcmData.Cols-1)
@@ -201,3 +214,68 @@ func (sliceFrame SliceFrame) Cut(v Volume) {
 	}
 	*sliceFrame.Mpr = Mpr(image, imgWidth, imgHeight, v.DcmData, false)
 }
+
+// CrossLine returns where other's plane crosses this frame's image, as two
+// points in mm from the image's top left corner clipped to its extent. ok is
+// false when the planes are parallel or meet outside the image.
+func (sliceFrame SliceFrame) CrossLine(other SliceFrame) (*math32.Vector2, *math32.Vector2, bool) {
+	w := sliceFrame.ImageSizeInMm.X
+	h := sliceFrame.ImageSizeInMm.Y
+	dist := func(u float32, v float32) float32 {
+		p := sliceFrame.PlaneToPatient(sliceFrame.Box2f.Min.X+u, sliceFrame.Box2f.Min.Y+v)
+		return other.RotatedFrame.Plane.DistanceToPoint(p)
+	}
+	d0 := dist(0, 0)
+	du := dist(1, 0) - d0
+	dv := dist(0, 1) - d0
+	const eps = 1e-6
+
+	var pts []*math32.Vector2
+	add := func(u float32, v float32) {
+		if u < -eps || u > w+eps || v < -eps || v > h+eps {
+			return
+		}
+		for _, p := range pts {
+			if math32.Abs(p.X-u) <= 1e-3 && math32.Abs(p.Y-v) <= 1e-3 {
+				return
+			}
+		}
+		pts = append(pts, math32.NewVector2(u, v))
+	}
+	if math32.Abs(dv) > eps {
+		add(0, -d0/dv)
+		add(w, -(d0+w*du)/dv)
+	}
+	if math32.Abs(du) > eps {
+		add(-d0/du, 0)
+		add(-(d0+h*dv)/du, h)
+	}
+	if len(pts) < 2 {
+		return nil, nil, false
+	}
+	return pts[0], pts[1], true
+}
+
+// Region returns a frame on the same plane whose image covers only box, in the
+// same plane coordinates as Box2f, sampled every pixelSize mm. Cut it to get
+// a detailed reformat of part of the original image.
+func (sliceFrame SliceFrame) Region(box Box2f, pixelSize float32) SliceFrame {
+	region := sliceFrame
+	region.Box2f = box
+	region.ImageSizeInMm = math32.NewVector2(box.GetWidth(), box.GetHeigth())
+	region.ImageSize = math32.NewVector2(math32.Ceil(box.GetWidth()/pixelSize), math32.Ceil(box.GetHeigth()/pixelSize))
+	region.ImagePixelSize = math32.NewVector2(pixelSize, pixelSize)
+	mpr := &image.RGBA{}
+	region.Mpr = &mpr
+	return region
+}
+
+// PatientToPlane projects patient point p onto the plane and returns it in
+// mm along the basis x and y axes from the frame origin, the inverse of
+// PlaneToPatient.
+func (sliceFrame SliceFrame) PatientToPlane(p *math32.Vector3) *math32.Vector2 {
+	xDir := math32.NewVector3(1, 0, 0).ApplyMatrix4(sliceFrame.RotatedFrame.Basis).Normalize()
+	yDir := math32.NewVector3(0, 1, 0).ApplyMatrix4(sliceFrame.RotatedFrame.Basis).Normalize()
+	d := math32.NewVec3().SubVectors(p, sliceFrame.RotatedFrame.Origin)
+	return math32.NewVector2(d.Dot(xDir), d.Dot(yDir))
+}
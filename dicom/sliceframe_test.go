@@ -0,0 +1,119 @@
+package volume
+
+import (
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func offsetVolume() Volume {
+	dirX := math32.NewVector3(0, 1, 0)
+	dirY := math32.NewVector3(0, 0, -1)
+	dirZ := math32.NewVec3().CrossVectors(dirX, dirY)
+	orientation := math32.NewMatrix4().MakeBasis(dirX, dirY, dirZ)
+	return Volume{DcmData: NewDcmData(20, 30, 10, orientation, math32.NewVector3(-120, 45, 310), math32.NewVector3(0.8, 0.8, 2.5))}
+}
+
+func checkOnPlane(t *testing.T, frame SliceFrame, pts ...*math32.Vector3) {
+	t.Helper()
+	for _, p := range pts {
+		if d := frame.RotatedFrame.Plane.DistanceToPoint(p); math32.Abs(d) > 1e-3 {
+			t.Errorf("%v is %v mm from the plane", p, d)
+		}
+	}
+	if len(frame.Intersections) < 3 {
+		t.Errorf("plane misses the volume: %v", frame.Intersections)
+	}
+}
+
+func TestPointNormal(t *testing.T) {
+	v := offsetVolume()
+	point := math32.NewVector3(5, 3, 7).ApplyMatrix4(v.DcmData.Calibration)
+	frame := PointNormal(v, point, math32.NewVector3(1, 2, 3))
+	checkOnPlane(t, frame, point)
+}
+
+func TestThreePoints(t *testing.T) {
+	v := offsetVolume()
+	a := math32.NewVector3(2, 2, 2).ApplyMatrix4(v.DcmData.Calibration)
+	b := math32.NewVector3(25, 4, 6).ApplyMatrix4(v.DcmData.Calibration)
+	c := math32.NewVector3(10, 18, 3).ApplyMatrix4(v.DcmData.Calibration)
+	frame, err := ThreePoints(v, a, b, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkOnPlane(t, frame, a, b, c)
+
+	// Points on a line or falling together give no plane.
+	onLine := math32.NewVec3().Copy(b).MultiplyScalar(2).Sub(a)
+	for _, pts := range [][3]*math32.Vector3{{a, b, onLine}, {a, a, c}, {a, a, a}} {
+		if _, err := ThreePoints(v, pts[0], pts[1], pts[2]); err == nil {
+			t.Errorf("cut a plane through %v", pts)
+		}
+	}
+}
+
+func TestOrthogonalFollowVolumeAxes(t *testing.T) {
+	v := offsetVolume()
+	dirX, dirY, dirZ := axes(v)
+	tests := []struct {
+		name   string
+		frame  SliceFrame
+		normal *math32.Vector3
+		point  *math32.Vector3
+	}{
+		{"axial", Axial(v, 4), dirZ, math32.NewVector3(3, 7, 4)},
+		{"coronal", Coronal(v, 11), dirY, math32.NewVector3(3, 11, 8)},
+		{"sagittal", Sagittal(v, 17), dirX.Negate(), math32.NewVector3(17, 7, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normal := math32.NewVector3(0, 0, 1).ApplyMatrix4(tt.frame.RotatedFrame.Basis)
+			if normal.DistanceTo(tt.normal) > 1e-5 {
+				t.Errorf("normal = %v, want %v", normal, tt.normal)
+			}
+			checkOnPlane(t, tt.frame, tt.point.ApplyMatrix4(v.DcmData.Calibration))
+		})
+	}
+}
+
+func TestCrossLine(t *testing.T) {
+	v := Volume{DcmData: NewDcmData(20, 30, 10, math32.NewMatrix4(), math32.NewVector3(-50, 10, 5), math32.NewVector3(1, 1, 2))}
+	axial := Axial(v, 5)
+	coronal := Coronal(v, 12)
+	a, b, ok := axial.CrossLine(coronal)
+	if !ok {
+		t.Fatal("coronal plane does not cross the axial image")
+	}
+	for _, p := range []*math32.Vector2{a, b} {
+		pt := axial.PlaneToPatient(axial.Box2f.Min.X+p.X, axial.Box2f.Min.Y+p.Y)
+		if d := coronal.RotatedFrame.Plane.DistanceToPoint(pt); math32.Abs(d) > 1e-3 {
+			t.Errorf("end point %v is %v mm from the coronal plane", p, d)
+		}
+	}
+	if a.DistanceTo(b) < axial.ImageSizeInMm.X-1e-3 {
+		t.Errorf("line %v-%v does not span the image width %v", a, b, axial.ImageSizeInMm.X)
+	}
+	if _, _, ok := axial.CrossLine(Axial(v, 7)); ok {
+		t.Error("parallel planes reported as crossing")
+	}
+}
+
+func TestRegion(t *testing.T) {
+	v := offsetVolume()
+	frame := Axial(v, 3)
+	min := math32.NewVector2(frame.Box2f.Min.X+4, frame.Box2f.Min.Y+6)
+	max := math32.NewVector2(min.X+5, min.Y+3)
+	region := frame.Region(AABB2f([]*math32.Vector2{min, max}), 0.25)
+
+	if region.ImageSize.X != 20 || region.ImageSize.Y != 12 {
+		t.Errorf("ImageSize = %v, want 20x12", region.ImageSize)
+	}
+	want := frame.PlaneToPatient(min.X, min.Y)
+	if got := region.ImageOrigin(); got.DistanceTo(want) > 1e-4 {
+		t.Errorf("ImageOrigin = %v, want %v", got, want)
+	}
+	if region.Mpr == frame.Mpr {
+		t.Error("region shares the frame's image")
+	}
+}
@@ -0,0 +1,519 @@
+package volume
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/g3n/engine/math32"
+	"github.com/suyashkumar/dicom"
+	"github.com/suyashkumar/dicom/pkg/tag"
+	"github.com/suyashkumar/dicom/pkg/uid"
+)
+
+// Comprehensive 3D SR is the SR storage class that allows SCOORD3D, which
+// keeps measurements in patient coordinates like Measurement does.
+const comprehensive3DSR = "1.2.840.10008.5.1.4.1.1.88.34"
+
+type code struct {
+	value   string
+	scheme  string
+	meaning string
+}
+
+var (
+	codeReport         = code{"126000", "DCM", "Imaging Measurement Report"}
+	codeLanguage       = code{"121049", "DCM", "Language of Content Item and Descendants"}
+	codeEnglish        = code{"en-US", "RFC5646", "English (United States)"}
+	codeProcedure      = code{"121058", "DCM", "Procedure reported"}
+	codeImaging        = code{"363679005", "SCT", "Imaging procedure"}
+	codeLibrary        = code{"111028", "DCM", "Image Library"}
+	codeLibraryGroup   = code{"126200", "DCM", "Image Library Group"}
+	codeMeasurements   = code{"126010", "DCM", "Imaging Measurements"}
+	codeGroup          = code{"125007", "DCM", "Measurement Group"}
+	codeTrackingID     = code{"112039", "DCM", "Tracking Identifier"}
+	codeTrackingUID    = code{"112040", "DCM", "Tracking Unique Identifier"}
+	codeRegion         = code{"111030", "DCM", "Image Region"}
+	codeLength         = code{"410668003", "SCT", "Length"}
+	codeAngle          = code{"1483009", "SCT", "Angle"}
+	codeArea           = code{"42798000", "SCT", "Area"}
+	codeMean           = code{"373098007", "SCT", "Mean"}
+	codeStd            = code{"386136009", "SCT", "Standard Deviation"}
+	codeMin            = code{"255605001", "SCT", "Minimum"}
+	codeMax            = code{"56851009", "SCT", "Maximum"}
+	unitMillimeter     = code{"mm", "UCUM", "millimeter"}
+	unitSquareMm       = code{"mm2", "UCUM", "square millimeter"}
+	unitDegree         = code{"deg", "UCUM", "degree"}
+	unitHounsfield     = code{"[hnsf'U]", "UCUM", "Hounsfield unit"}
+	unitNone           = code{"1", "UCUM", "no units"}
+	unitBqPerMl        = code{"Bq/mL", "UCUM", "Becquerels/milliliter"}
+	unitSUVbw          = code{"{SUVbw}g/ml", "UCUM", "Standardized Uptake Value body weight"}
+	unitSUVlbm         = code{"{SUVlbm}g/ml", "UCUM", "Standardized Uptake Value lean body mass"}
+	unitSUVbsa         = code{"{SUVbsa}cm2/ml", "UCUM", "Standardized Uptake Value body surface area"}
+	graphicTypes       = map[MeasureKind]string{Distance: "POLYLINE", Angle: "POLYLINE", Polyline: "POLYLINE", Ellipse: "ELLIPSE", Freehand: "POLYGON"}
+	errNotMeasurements = fmt.Errorf("not an imaging measurement report")
+)
+
+// contentItem is a node of the SR content tree.
+type contentItem struct {
+	relationship string
+	valueType    string
+	name         code
+	text         string
+	uid          string
+	concept      code
+	number       float32
+	unit         code
+	graphicType  string
+	points       []math32.Vector3
+	frameUID     string
+	sopClass     string
+	sopInstance  string
+	children     []contentItem
+}
+
+// elements collects dataset elements, keeping the first error.
+type elements struct {
+	list []*dicom.Element
+	err  error
+}
+
+func (e *elements) add(t tag.Tag, value interface{}) {
+	if e.err != nil {
+		return
+	}
+	element, err := dicom.NewElement(t, value)
+	if err != nil {
+		e.err = fmt.Errorf("%v: %w", t, err)
+		return
+	}
+	e.list = append(e.list, element)
+}
+
+func (e *elements) addCode(t tag.Tag, c code) {
+	item := &elements{}
+	item.add(tag.CodeValue, []string{c.value})
+	item.add(tag.CodingSchemeDesignator, []string{c.scheme})
+	item.add(tag.CodeMeaning, []string{c.meaning})
+	if item.err != nil {
+		e.err = item.err
+		return
+	}
+	e.add(t, [][]*dicom.Element{item.list})
+}
+
+func (e *elements) addContent(items []contentItem) {
+	var sequence [][]*dicom.Element
+	for _, item := range items {
+		child := &elements{}
+		item.write(child)
+		if child.err != nil {
+			e.err = child.err
+			return
+		}
+		sequence = append(sequence, child.list)
+	}
+	e.add(tag.ContentSequence, sequence)
+}
+
+func (item contentItem) write(e *elements) {
+	if item.relationship != "" {
+		e.add(tag.RelationshipType, []string{item.relationship})
+	}
+	e.add(tag.ValueType, []string{item.valueType})
+	e.addCode(tag.ConceptNameCodeSequence, item.name)
+	switch item.valueType {
+	case "CONTAINER":
+		e.add(tag.ContinuityOfContent, []string{"SEPARATE"})
+	case "TEXT":
+		e.add(tag.TextValue, []string{item.text})
+	case "UIDREF":
+		e.add(tag.UID, []string{item.uid})
+	case "CODE":
+		e.addCode(tag.ConceptCodeSequence, item.concept)
+	case "NUM":
+		value := &elements{}
+		value.addCode(tag.MeasurementUnitsCodeSequence, item.unit)
+		value.add(tag.NumericValue, []string{strconv.FormatFloat(float64(item.number), 'g', 8, 32)})
+		if value.err != nil {
+			e.err = value.err
+			return
+		}
+		e.add(tag.MeasuredValueSequence, [][]*dicom.Element{value.list})
+	case "SCOORD3D":
+		var data []float64
+		for _, p := range item.points {
+			data = append(data, float64(p.X), float64(p.Y), float64(p.Z))
+		}
+		e.add(tag.GraphicType, []string{item.graphicType})
+		e.add(tag.GraphicData, data)
+		e.add(tag.ReferencedFrameOfReferenceUID, []string{item.frameUID})
+	case "IMAGE":
+		ref := &elements{}
+		ref.add(tag.ReferencedSOPClassUID, []string{item.sopClass})
+		ref.add(tag.ReferencedSOPInstanceUID, []string{item.sopInstance})
+		if ref.err != nil {
+			e.err = ref.err
+			return
+		}
+		e.add(tag.ReferencedSOPSequence, [][]*dicom.Element{ref.list})
+	}
+	if len(item.children) > 0 {
+		e.addContent(item.children)
+	}
+}
+
+func stringOf(ds dicom.Dataset, t tag.Tag) string {
+	element, err := ds.FindElementByTag(t)
+	if err != nil {
+		return ""
+	}
+	return stringValue(element)
+}
+
+// stringValue returns the first string of e, empty when it holds none.
+func stringValue(e *dicom.Element) string {
+	strs, ok := e.Value.GetValue().([]string)
+	if !ok || len(strs) == 0 {
+		return ""
+	}
+	return strs[0]
+}
+
+func newUID() (string, error) {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", err
+	}
+	return "2.25." + n.String(), nil
+}
+
+// measurementGroup builds the TID 1410/1501 style group of one measurement:
+// its tracking identifiers, the region in patient coordinates and the values
+// measured from it.
+func measurementGroup(v Volume, m Measurement, index int, frameUID string, unit code) (contentItem, error) {
+	trackingUID, err := newUID()
+	if err != nil {
+		return contentItem{}, err
+	}
+	points := m.Points
+	if m.Kind == Freehand {
+		// SR polygons are closed by repeating the first point
+		points = append(append([]math32.Vector3{}, points...), points[0])
+	}
+	region := contentItem{valueType: "SCOORD3D", name: codeRegion, graphicType: graphicTypes[m.Kind], points: points, frameUID: frameUID}
+	group := contentItem{relationship: "CONTAINS", valueType: "CONTAINER", name: codeGroup, children: []contentItem{
+		{relationship: "HAS OBS CONTEXT", valueType: "TEXT", name: codeTrackingID, text: fmt.Sprintf("%s %d", m.Kind, index+1)},
+		{relationship: "HAS OBS CONTEXT", valueType: "UIDREF", name: codeTrackingUID, uid: trackingUID},
+	}}
+	num := func(name code, value float32, unit code) contentItem {
+		return contentItem{relationship: "CONTAINS", valueType: "NUM", name: name, number: value, unit: unit}
+	}
+	if m.IsRegion() {
+		region.relationship = "CONTAINS"
+		s := m.Stats(v)
+		group.children = append(group.children, region,
+			num(codeArea, s.Area, unitSquareMm),
+			num(codeMean, s.Mean, unit),
+			num(codeStd, s.Std, unit),
+			num(codeMin, s.Min, unit),
+			num(codeMax, s.Max, unit))
+		return group, nil
+	}
+	region.relationship = "INFERRED FROM"
+	measured := num(codeLength, m.Length(), unitMillimeter)
+	if m.Kind == Angle {
+		measured = num(codeAngle, m.Degrees(), unitDegree)
+	}
+	measured.children = []contentItem{region}
+	group.children = append(group.children, measured)
+	return group, nil
+}
+
+// WriteSR saves measurements to path as a Comprehensive 3D SR following
+// TID 1500, Measurement Report. The report joins the study of v and lists
+// its images in the image library; the measurements themselves are stored
+// as SCOORD3D in the volume's frame of reference.
+func WriteSR(path string, v Volume, measurements []Measurement) error {
+	var source dicom.Dataset
+	if len(v.Dicoms) > 0 {
+		source = v.Dicoms[0].dataset
+	}
+	studyUID := stringOf(source, tag.StudyInstanceUID)
+	frameUID := stringOf(source, tag.FrameOfReferenceUID)
+	var err error
+	if studyUID == "" {
+		if studyUID, err = newUID(); err != nil {
+			return err
+		}
+	}
+	if frameUID == "" {
+		if frameUID, err = newUID(); err != nil {
+			return err
+		}
+	}
+	seriesUID, err := newUID()
+	if err != nil {
+		return err
+	}
+	sopUID, err := newUID()
+	if err != nil {
+		return err
+	}
+	unit := unitNone
+	switch v.DcmData.Units {
+	case "HU":
+		unit = unitHounsfield
+	case "BQML":
+		unit = unitBqPerMl
+	case SUVbw.String():
+		unit = unitSUVbw
+	case SUVlbm.String():
+		unit = unitSUVlbm
+	case SUVbsa.String():
+		unit = unitSUVbsa
+	default:
+		if stringOf(source, tag.Modality) == "CT" {
+			unit = unitHounsfield
+		}
+	}
+
+	library := contentItem{relationship: "CONTAINS", valueType: "CONTAINER", name: codeLibrary}
+	libraryGroup := contentItem{relationship: "CONTAINS", valueType: "CONTAINER", name: codeLibraryGroup}
+	var referenced [][]*dicom.Element
+	for _, dcm := range v.Dicoms {
+		sopClass := stringOf(dcm.dataset, tag.SOPClassUID)
+		sopInstance := stringOf(dcm.dataset, tag.SOPInstanceUID)
+		libraryGroup.children = append(libraryGroup.children, contentItem{
+			relationship: "CONTAINS", valueType: "IMAGE", sopClass: sopClass, sopInstance: sopInstance,
+			name: code{"111027", "DCM", "Image Library Entry"}})
+		ref := &elements{}
+		ref.add(tag.ReferencedSOPClassUID, []string{sopClass})
+		ref.add(tag.ReferencedSOPInstanceUID, []string{sopInstance})
+		if ref.err != nil {
+			return ref.err
+		}
+		referenced = append(referenced, ref.list)
+	}
+	if len(libraryGroup.children) > 0 {
+		library.children = []contentItem{libraryGroup}
+	}
+
+	imaging := contentItem{relationship: "CONTAINS", valueType: "CONTAINER", name: codeMeasurements}
+	for i, m := range measurements {
+		group, err := measurementGroup(v, m, i, frameUID, unit)
+		if err != nil {
+			return err
+		}
+		imaging.children = append(imaging.children, group)
+	}
+	content := []contentItem{
+		{relationship: "HAS CONCEPT MOD", valueType: "CODE", name: codeLanguage, concept: codeEnglish},
+		{relationship: "HAS CONCEPT MOD", valueType: "CODE", name: codeProcedure, concept: codeImaging},
+		library,
+		imaging,
+	}
+
+	now := time.Now()
+	ds := &elements{}
+	ds.add(tag.MediaStorageSOPClassUID, []string{comprehensive3DSR})
+	ds.add(tag.MediaStorageSOPInstanceUID, []string{sopUID})
+	ds.add(tag.TransferSyntaxUID, []string{uid.ExplicitVRLittleEndian})
+	ds.add(tag.SOPClassUID, []string{comprehensive3DSR})
+	ds.add(tag.SOPInstanceUID, []string{sopUID})
+	ds.add(tag.Modality, []string{"SR"})
+	ds.add(tag.PatientName, []string{stringOf(source, tag.PatientName)})
+	ds.add(tag.PatientID, []string{stringOf(source, tag.PatientID)})
+	ds.add(tag.StudyInstanceUID, []string{studyUID})
+	ds.add(tag.SeriesInstanceUID, []string{seriesUID})
+	ds.add(tag.SeriesNumber, []string{"1000"})
+	ds.add(tag.InstanceNumber, []string{"1"})
+	ds.add(tag.ContentDate, []string{now.Format("20060102")})
+	ds.add(tag.ContentTime, []string{now.Format("150405")})
+	ds.add(tag.ValueType, []string{"CONTAINER"})
+	ds.addCode(tag.ConceptNameCodeSequence, codeReport)
+	ds.add(tag.ContinuityOfContent, []string{"SEPARATE"})
+	template := &elements{}
+	template.add(tag.MappingResource, []string{"DCMR"})
+	template.add(tag.TemplateIdentifier, []string{"1500"})
+	if template.err != nil {
+		return template.err
+	}
+	ds.add(tag.ContentTemplateSequence, [][]*dicom.Element{template.list})
+	ds.add(tag.CompletionFlag, []string{"COMPLETE"})
+	ds.add(tag.VerificationFlag, []string{"UNVERIFIED"})
+	if len(referenced) > 0 {
+		series := &elements{}
+		series.add(tag.SeriesInstanceUID, []string{stringOf(source, tag.SeriesInstanceUID)})
+		series.add(tag.ReferencedSOPSequence, referenced)
+		study := &elements{}
+		study.add(tag.StudyInstanceUID, []string{studyUID})
+		if series.err == nil {
+			study.add(tag.ReferencedSeriesSequence, [][]*dicom.Element{series.list})
+		}
+		if series.err != nil || study.err != nil {
+			return fmt.Errorf("evidence: %v %v", series.err, study.err)
+		}
+		ds.add(tag.CurrentRequestedProcedureEvidenceSequence, [][]*dicom.Element{study.list})
+	}
+	ds.addContent(content)
+	if ds.err != nil {
+		return ds.err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = dicom.Write(f, dicom.Dataset{Elements: ds.list})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadSR loads the measurements of a TID 1500 report written by WriteSR or
+// another tool that stores regions as SCOORD3D. Planar SCOORD regions are
+// skipped, as they would need the referenced images to place them.
+func ReadSR(path string) ([]Measurement, error) {
+	ds, err := dicom.ParseFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	name, err := ds.FindElementByTag(tag.ConceptNameCodeSequence)
+	if err != nil || codeOf(sequenceItems(name)) != codeReport.value {
+		return nil, errNotMeasurements
+	}
+	content, err := ds.FindElementByTag(tag.ContentSequence)
+	if err != nil {
+		return nil, nil
+	}
+	var measurements []Measurement
+	for _, group := range findItems(sequenceItems(content), codeGroup.value) {
+		if m, ok := readGroup(group); ok {
+			measurements = append(measurements, m)
+		}
+	}
+	return measurements, nil
+}
+
+func sequenceItems(e *dicom.Element) [][]*dicom.Element {
+	values, ok := e.Value.GetValue().([]*dicom.SequenceItemValue)
+	if !ok {
+		return nil
+	}
+	var items [][]*dicom.Element
+	for _, v := range values {
+		if item, ok := v.GetValue().([]*dicom.Element); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func find(item []*dicom.Element, t tag.Tag) *dicom.Element {
+	for _, e := range item {
+		if e.Tag == t {
+			return e
+		}
+	}
+	return nil
+}
+
+// codeOf returns the code value of the first item of a code sequence.
+func codeOf(items [][]*dicom.Element) string {
+	if len(items) == 0 {
+		return ""
+	}
+	if e := find(items[0], tag.CodeValue); e != nil {
+		return stringValue(e)
+	}
+	return ""
+}
+
+func conceptOf(item []*dicom.Element) string {
+	if e := find(item, tag.ConceptNameCodeSequence); e != nil {
+		return codeOf(sequenceItems(e))
+	}
+	return ""
+}
+
+func childrenOf(item []*dicom.Element) [][]*dicom.Element {
+	if e := find(item, tag.ContentSequence); e != nil {
+		return sequenceItems(e)
+	}
+	return nil
+}
+
+// findItems walks the content tree depth first for items named concept.
+func findItems(items [][]*dicom.Element, concept string) [][]*dicom.Element {
+	var found [][]*dicom.Element
+	for _, item := range items {
+		if conceptOf(item) == concept {
+			found = append(found, item)
+			continue
+		}
+		found = append(found, findItems(childrenOf(item), concept)...)
+	}
+	return found
+}
+
+// readGroup turns the first SCOORD3D of a measurement group back into a
+// measurement, telling angles from lengths by the measured concept.
+func readGroup(group []*dicom.Element) (Measurement, bool) {
+	var scoord []*dicom.Element
+	angle := false
+	var walk func(items [][]*dicom.Element)
+	walk = func(items [][]*dicom.Element) {
+		for _, item := range items {
+			if conceptOf(item) == codeAngle.value {
+				angle = true
+			}
+			if e := find(item, tag.ValueType); e != nil && scoord == nil && stringValue(e) == "SCOORD3D" {
+				scoord = item
+			}
+			walk(childrenOf(item))
+		}
+	}
+	walk(childrenOf(group))
+	if scoord == nil {
+		return Measurement{}, false
+	}
+	typeElement, data := find(scoord, tag.GraphicType), find(scoord, tag.GraphicData)
+	if typeElement == nil || data == nil {
+		return Measurement{}, false
+	}
+	floats, ok := data.Value.GetValue().([]float64)
+	if !ok {
+		return Measurement{}, false
+	}
+	var m Measurement
+	for i := 0; i+2 < len(floats); i += 3 {
+		m.Points = append(m.Points, math32.Vector3{X: float32(floats[i]), Y: float32(floats[i+1]), Z: float32(floats[i+2])})
+	}
+	switch stringValue(typeElement) {
+	case "ELLIPSE":
+		m.Kind = Ellipse
+	case "POLYGON":
+		m.Kind = Freehand
+		// Closed polygons may repeat the first point at the end
+		if n := len(m.Points); n > 1 && m.Points[0] == m.Points[n-1] {
+			m.Points = m.Points[:n-1]
+		}
+	case "POLYLINE":
+		switch {
+		case angle:
+			m.Kind = Angle
+		case len(m.Points) == 2:
+			m.Kind = Distance
+		default:
+			m.Kind = Polyline
+		}
+	default:
+		return Measurement{}, false
+	}
+	return m, m.Complete()
+}
@@ -0,0 +1,124 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"awesomeProject/phantom"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+	"github.com/suyashkumar/dicom"
+	"github.com/suyashkumar/dicom/pkg/tag"
+)
+
+func TestSRRoundTrip(t *testing.T) {
+	p := phantom.New(24, 24, 6, 2)
+	p.Shapes = []phantom.Shape{phantom.Sphere{Center: p.VoxelCenter(12, 12, 3), Radius: 10, Value: 300}}
+	dir := t.TempDir()
+	if err := p.WriteSeries(filepath.Join(dir, "series")); err != nil {
+		t.Fatal(err)
+	}
+	v := volume.New(filepath.Join(dir, "series"))
+	frame := volume.Axial(v, 3)
+	at := func(c float32, r float32) math32.Vector3 {
+		return *frame.PlaneToPatient(frame.Box2f.Min.X+c, frame.Box2f.Min.Y+r)
+	}
+	a, b := at(10, 10), at(30, 24)
+	want := []volume.Measurement{
+		{Kind: volume.Distance, Points: []math32.Vector3{at(2, 3), at(20, 30)}},
+		{Kind: volume.Angle, Points: []math32.Vector3{at(2, 3), at(20, 30), at(40, 3)}},
+		{Kind: volume.Polyline, Points: []math32.Vector3{at(2, 3), at(20, 30), at(40, 3), at(44, 44)}},
+		volume.EllipseFromCorners(frame, &a, &b),
+		{Kind: volume.Freehand, Points: []math32.Vector3{at(5, 5), at(30, 8), at(20, 40)}},
+	}
+
+	path := filepath.Join(dir, "report.dcm")
+	if err := volume.WriteSR(path, v, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := volume.ReadSR(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("read %d measurements, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Kind != want[i].Kind || len(got[i].Points) != len(want[i].Points) {
+			t.Errorf("measurement %d = %v, want %v", i, got[i], want[i])
+			continue
+		}
+		for j := range want[i].Points {
+			if got[i].Points[j].DistanceTo(&want[i].Points[j]) > 1e-3 {
+				t.Errorf("measurement %d point %d = %v, want %v", i, j, got[i].Points[j], want[i].Points[j])
+			}
+		}
+	}
+
+	ds, err := dicom.ParseFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	referenced := map[string]int{}
+	for it := ds.FlatStatefulIterator(); it.HasNext(); {
+		if e := it.Next(); e.Tag == tag.ReferencedSOPInstanceUID {
+			referenced[e.Value.GetValue().([]string)[0]]++
+		}
+	}
+	for _, uid := range v.SOPInstanceUIDs() {
+		// once in the evidence sequence and once in the image library
+		if referenced[uid] != 2 {
+			t.Errorf("%s referenced %d times", uid, referenced[uid])
+		}
+	}
+}
+
+func TestReadForeignSR(t *testing.T) {
+	p := phantom.New(8, 8, 2, 2)
+	v := p.Volume()
+	frame := volume.Axial(v, 1)
+	at := func(c float32, r float32) math32.Vector3 {
+		return *frame.PlaneToPatient(frame.Box2f.Min.X+c, frame.Box2f.Min.Y+r)
+	}
+	path := filepath.Join(t.TempDir(), "report.dcm")
+	err := volume.WriteSR(path, v, []volume.Measurement{
+		{Kind: volume.Distance, Points: []math32.Vector3{at(1, 1), at(5, 5)}},
+		{Kind: volume.Distance, Points: []math32.Vector3{at(2, 1), at(5, 6)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another tool leaves the first graphic type empty.
+	ds, err := dicom.ParseFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptied := false
+	for it := ds.FlatStatefulIterator(); it.HasNext(); {
+		if e := it.Next(); e.Tag == tag.GraphicType && !emptied {
+			e.Value, _ = dicom.NewValue([]string{})
+			emptied = true
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dicom.Write(f, ds)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := volume.ReadSR(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := at(2, 1)
+	if len(got) != 1 || got[0].Points[0].DistanceTo(&second) > 1e-3 {
+		t.Errorf("read %v, want only the second distance", got)
+	}
+}
@@ -0,0 +1,269 @@
+package volume
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suyashkumar/dicom"
+	"github.com/suyashkumar/dicom/pkg/tag"
+)
+
+// ValueMode is what a PET series' values are shown as: the activity stored,
+// or a standardized uptake value normalised by body weight, lean body mass
+// or body surface area.
+type ValueMode int
+
+const (
+	RawValues ValueMode = iota
+	SUVbw
+	SUVlbm
+	SUVbsa
+)
+
+var valueModeNames = []string{"raw", "SUVbw", "SUVlbm", "SUVbsa"}
+
+func (m ValueMode) String() string {
+	return enumName(valueModeNames, "ValueMode", int(m))
+}
+
+// ParseValueMode reads a mode written as by String, in any case.
+func ParseValueMode(s string) (ValueMode, error) {
+	for i, name := range valueModeNames {
+		if strings.EqualFold(name, s) {
+			return ValueMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown value mode %q", s)
+}
+
+// SUVParams are what standardized uptake values are computed from.
+type SUVParams struct {
+	// Weight is in kg and Height in m, zero when not recorded.
+	Weight float32
+	Height float32
+	Male   bool
+	// Dose is the activity injected in Bq, decaying with HalfLife in s.
+	Dose     float32
+	HalfLife float32
+	// Decay is, per slice, the fraction of the dose left at the time the
+	// slice's activity is corrected to.
+	Decay []float32
+}
+
+// SUV reads the parameters of a PET series from its headers.
+func (volume Volume) SUV() (SUVParams, error) {
+	datasets := make([]dicom.Dataset, len(volume.Dicoms))
+	for i, dcm := range volume.Dicoms {
+		datasets[i] = dcm.dataset
+	}
+	return ReadSUV(datasets)
+}
+
+// ReadSUV reads the parameters of a PET series, one dataset per slice, from
+// the patient's weight, height and sex and the Radiopharmaceutical
+// Information Sequence. The activity must be in Bq/ml and decay corrected to
+// the series start (START), to the injection (ADMIN), or not at all (NONE),
+// when each slice decays to its acquisition.
+func ReadSUV(datasets []dicom.Dataset) (SUVParams, error) {
+	var p SUVParams
+	if len(datasets) == 0 {
+		return p, fmt.Errorf("no slices")
+	}
+	ds := datasets[0]
+	if units := stringOf(ds, tag.Units); units != "BQML" {
+		return p, fmt.Errorf("PET units %q, want BQML", units)
+	}
+	p.Weight = floatOf(ds, tag.PatientWeight)
+	if p.Weight <= 0 {
+		return p, fmt.Errorf("no patient weight")
+	}
+	p.Height = floatOf(ds, tag.PatientSize)
+	p.Male = stringOf(ds, tag.PatientSex) == "M"
+
+	info, err := ds.FindElementByTag(tag.RadiopharmaceuticalInformationSequence)
+	if err != nil {
+		return p, fmt.Errorf("no radiopharmaceutical information")
+	}
+	items := sequenceItems(info)
+	if len(items) == 0 {
+		return p, fmt.Errorf("no radiopharmaceutical information")
+	}
+	item := dicom.Dataset{Elements: items[0]}
+	p.Dose = floatOf(item, tag.RadionuclideTotalDose)
+	p.HalfLife = floatOf(item, tag.RadionuclideHalfLife)
+	if p.Dose <= 0 || p.HalfLife <= 0 {
+		return p, fmt.Errorf("no injected dose or half life")
+	}
+
+	correction := stringOf(ds, tag.DecayCorrection)
+	var injected time.Time
+	if correction != "ADMIN" {
+		injected, err = dateTimeOf(stringOf(item, tag.RadiopharmaceuticalStartDateTime), "",
+			stringOf(item, tag.RadiopharmaceuticalStartTime))
+		if err != nil {
+			return p, fmt.Errorf("injection time: %w", err)
+		}
+	}
+	// Only the time of day may be known, in which case the scan started
+	// less than a day after the injection.
+	elapsed := func(date string, clock string) (float64, error) {
+		at, err := dateTimeOf("", date, clock)
+		if err != nil {
+			return 0, err
+		}
+		if injected.Year() == 0 || at.Year() == 0 {
+			at = time.Date(0, 1, 1, at.Hour(), at.Minute(), at.Second(), at.Nanosecond(), time.UTC)
+			day := time.Date(0, 1, 1, injected.Hour(), injected.Minute(), injected.Second(), injected.Nanosecond(), time.UTC)
+			if at.Before(day) {
+				at = at.Add(24 * time.Hour)
+			}
+			return at.Sub(day).Seconds(), nil
+		}
+		return at.Sub(injected).Seconds(), nil
+	}
+	decay := func(seconds float64) float32 {
+		return float32(math.Pow(2, -seconds/float64(p.HalfLife)))
+	}
+
+	p.Decay = make([]float32, len(datasets))
+	switch correction {
+	case "ADMIN":
+		for i := range p.Decay {
+			p.Decay[i] = 1
+		}
+	case "NONE":
+		for i, slice := range datasets {
+			seconds, err := elapsed(stringOf(slice, tag.AcquisitionDate), stringOf(slice, tag.AcquisitionTime))
+			if err != nil {
+				return p, fmt.Errorf("slice %d acquisition time: %w", i, err)
+			}
+			p.Decay[i] = decay(seconds)
+		}
+	case "START", "":
+		seconds, err := elapsed(stringOf(ds, tag.SeriesDate), stringOf(ds, tag.SeriesTime))
+		if err != nil {
+			return p, fmt.Errorf("series time: %w", err)
+		}
+		for i := range p.Decay {
+			p.Decay[i] = decay(seconds)
+		}
+	default:
+		return p, fmt.Errorf("decay correction %q", correction)
+	}
+	return p, nil
+}
+
+// LeanBodyMass is by James' formula, in kg.
+func (p SUVParams) LeanBodyMass() float32 {
+	w, h := p.Weight, p.Height*100
+	if p.Male {
+		return 1.10*w - 128*(w/h)*(w/h)
+	}
+	return 1.07*w - 148*(w/h)*(w/h)
+}
+
+// BodySurfaceArea is by Du Bois' formula, in m².
+func (p SUVParams) BodySurfaceArea() float32 {
+	return 0.007184 * float32(math.Pow(float64(p.Weight), 0.425)*math.Pow(float64(p.Height*100), 0.725))
+}
+
+// Factor turns slice's Bq/ml into mode's values.
+func (p SUVParams) Factor(mode ValueMode, slice int) (float32, error) {
+	dose := p.Dose * p.Decay[slice]
+	switch mode {
+	case RawValues:
+		return 1, nil
+	case SUVbw:
+		return p.Weight * 1000 / dose, nil
+	case SUVlbm, SUVbsa:
+		if p.Height <= 0 {
+			return 0, fmt.Errorf("%s needs the patient's height", mode)
+		}
+		if mode == SUVlbm {
+			return p.LeanBodyMass() * 1000 / dose, nil
+		}
+		return p.BodySurfaceArea() * 1e4 / dose, nil
+	}
+	return 0, fmt.Errorf("unknown value mode %d", mode)
+}
+
+// InMode returns a copy of the PET volume v, in Bq/ml, with values in mode.
+// The window scales along so that the images look alike.
+func (volume Volume) InMode(p SUVParams, mode ValueMode) (Volume, error) {
+	if len(p.Decay) != len(volume.Values) {
+		return volume, fmt.Errorf("parameters for %d slices, volume has %d", len(p.Decay), len(volume.Values))
+	}
+	values := make([][][]float32, len(volume.Values))
+	for z, slice := range volume.Values {
+		f, err := p.Factor(mode, z)
+		if err != nil {
+			return volume, err
+		}
+		values[z] = make([][]float32, len(slice))
+		for r, row := range slice {
+			values[z][r] = make([]float32, len(row))
+			for c, value := range row {
+				values[z][r][c] = value * f
+			}
+		}
+	}
+	data := volume.DcmData
+	if f, err := p.Factor(mode, len(values)/2); err == nil {
+		data.Window *= f
+		data.Level *= f
+	}
+	data.Units = "BQML"
+	if mode != RawValues {
+		data.Units = mode.String()
+	}
+	out := FromValues(data, values)
+	out.Dicoms = volume.Dicoms
+	return out, nil
+}
+
+func floatOf(ds dicom.Dataset, t tag.Tag) float32 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(stringOf(ds, t)), 32)
+	if err != nil {
+		return 0
+	}
+	return float32(f)
+}
+
+// dateTimeOf reads a DICOM DT, or failing that a DA and TM, as UTC. Without
+// a date the year is zero.
+func dateTimeOf(dt string, da string, tm string) (time.Time, error) {
+	if dt = strings.TrimSpace(dt); len(dt) >= 14 {
+		// Any UTC offset is the same for both times compared.
+		if i := strings.IndexAny(dt, "+-"); i > 0 {
+			dt = dt[:i]
+		}
+		da, tm = dt[:8], dt[8:]
+	}
+	tm = strings.TrimSpace(tm)
+	if len(tm) < 4 {
+		return time.Time{}, fmt.Errorf("time %q", tm)
+	}
+	clock := tm
+	if len(clock) == 4 {
+		clock += "00"
+	}
+	layout := "150405"
+	if strings.Contains(clock, ".") {
+		layout = "150405.999999"
+	}
+	at, err := time.Parse(layout, clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if da = strings.TrimSpace(da); da != "" {
+		day, err := time.Parse("20060102", da)
+		if err != nil {
+			return time.Time{}, err
+		}
+		at = time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), at.Second(), at.Nanosecond(), time.UTC)
+	}
+	return at, nil
+}
@@ -0,0 +1,96 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"awesomeProject/phantom"
+	"math"
+	"testing"
+
+	"github.com/suyashkumar/dicom"
+	"github.com/suyashkumar/dicom/pkg/tag"
+)
+
+func petSlice(t *testing.T, correction string, acquired string) dicom.Dataset {
+	t.Helper()
+	element := func(tg tag.Tag, value interface{}) *dicom.Element {
+		e, err := dicom.NewElement(tg, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	drug := []*dicom.Element{
+		element(tag.RadiopharmaceuticalStartTime, []string{"093000.00"}),
+		element(tag.RadionuclideTotalDose, []string{"370000000"}),
+		element(tag.RadionuclideHalfLife, []string{"6586.2"}),
+	}
+	return dicom.Dataset{Elements: []*dicom.Element{
+		element(tag.PatientSex, []string{"M"}),
+		element(tag.PatientSize, []string{"1.8"}),
+		element(tag.PatientWeight, []string{"80"}),
+		element(tag.SeriesTime, []string{"103000"}),
+		element(tag.AcquisitionTime, []string{acquired}),
+		element(tag.RadiopharmaceuticalInformationSequence, [][]*dicom.Element{drug}),
+		element(tag.Units, []string{"BQML"}),
+		element(tag.DecayCorrection, []string{correction}),
+	}}
+}
+
+func TestSUV(t *testing.T) {
+	// 370 MBq of F-18 an hour before the series: 80 kg over what is left.
+	p, err := volume.ReadSUV([]dicom.Dataset{petSlice(t, "START", "103000"), petSlice(t, "START", "110000")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := 370e6 * math.Pow(2, -3600/6586.2)
+	want := 80e3 / left
+	for slice := 0; slice < 2; slice++ {
+		if f, _ := p.Factor(volume.SUVbw, slice); math.Abs(float64(f)-want)/want > 1e-4 {
+			t.Errorf("SUVbw factor %v, want %v", f, want)
+		}
+	}
+	lbm, _ := p.Factor(volume.SUVlbm, 0)
+	bsa, _ := p.Factor(volume.SUVbsa, 0)
+	if m := p.LeanBodyMass(); m < 60 || m > 65 {
+		t.Errorf("lean body mass %v kg", m)
+	}
+	if a := p.BodySurfaceArea(); a < 1.95 || a > 2.05 {
+		t.Errorf("body surface area %v m²", a)
+	}
+	if lbm <= 0 || bsa <= 0 {
+		t.Errorf("lbm %v, bsa %v", lbm, bsa)
+	}
+
+	// Uncorrected slices decay to their own acquisition.
+	p, err = volume.ReadSUV([]dicom.Dataset{petSlice(t, "NONE", "103000"), petSlice(t, "NONE", "113000")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := p.Decay[1] / p.Decay[0]; math.Abs(float64(r)-math.Pow(2, -3600/6586.2)) > 1e-4 {
+		t.Errorf("decay between slices %v", r)
+	}
+	if _, err := volume.ReadSUV([]dicom.Dataset{{}}); err == nil {
+		t.Error("SUV read from nothing")
+	}
+
+	v := phantom.New(4, 4, 2, 1).Volume()
+	suv, err := v.InMode(p, volume.SUVbw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _ := p.Factor(volume.SUVbw, 1)
+	if got := suv.Values[1][0][0]; got != v.Values[1][0][0]*f || suv.DcmData.Units != "SUVbw" {
+		t.Errorf("SUV %v %s, want %v", got, suv.DcmData.Units, v.Values[1][0][0]*f)
+	}
+}
+
+func TestValueModeNames(t *testing.T) {
+	for _, mode := range []volume.ValueMode{volume.RawValues, volume.SUVbw, volume.SUVlbm, volume.SUVbsa} {
+		if got, err := volume.ParseValueMode(mode.String()); err != nil || got != mode {
+			t.Errorf("%v read back as %v, %v", mode, got, err)
+		}
+	}
+	if name := volume.ValueMode(9).String(); name != "ValueMode(9)" {
+		t.Errorf("invalid mode named %q", name)
+	}
+}
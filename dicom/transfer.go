@@ -0,0 +1,135 @@
+package volume
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/g3n/engine/math32"
+)
+
+// TransferPoint gives the colour and opacity at one value, HU for CT.
+// Opacity is per voxel of ray travelled.
+type TransferPoint struct {
+	Value   float32
+	Color   math32.Color
+	Opacity float32
+}
+
+// TransferFunction maps values to colour and opacity, interpolating linearly
+// between its points, kept in order of value, and holding the end points
+// beyond them.
+type TransferFunction struct {
+	Name   string
+	Points []TransferPoint
+}
+
+// TransferPresets returns the built-in transfer functions. The CT ones are
+// in HU; MR intensities have no fixed scale, so the MR one spreads over the
+// window center, width given.
+func TransferPresets(center float32, width float32) []TransferFunction {
+	low, high := center-width/2, center+width/2
+	return []TransferFunction{
+		{"CT bone", []TransferPoint{
+			{150, math32.Color{0.7, 0.4, 0.3}, 0},
+			{300, math32.Color{0.95, 0.85, 0.7}, 0.15},
+			{1000, math32.Color{1, 1, 0.95}, 0.6},
+			{3000, math32.Color{1, 1, 1}, 0.8},
+		}},
+		{"CT angio", []TransferPoint{
+			{100, math32.Color{0.5, 0.05, 0.05}, 0},
+			{200, math32.Color{0.85, 0.2, 0.15}, 0.1},
+			{400, math32.Color{1, 0.65, 0.5}, 0.4},
+			{1000, math32.Color{1, 1, 0.95}, 0.7},
+			{3000, math32.Color{1, 1, 1}, 0.8},
+		}},
+		{"MR default", []TransferPoint{
+			{low, math32.Color{0, 0, 0}, 0},
+			{low + width/4, math32.Color{0.6, 0.35, 0.25}, 0.02},
+			{center, math32.Color{0.9, 0.7, 0.55}, 0.1},
+			{high, math32.Color{1, 1, 0.9}, 0.5},
+		}},
+		{"Lung", []TransferPoint{
+			{-1000, math32.Color{0, 0, 0}, 0},
+			{-950, math32.Color{0.3, 0.3, 0.6}, 0},
+			{-800, math32.Color{0.85, 0.6, 0.5}, 0.08},
+			{-500, math32.Color{1, 0.85, 0.75}, 0.03},
+			{-300, math32.Color{1, 0.9, 0.8}, 0},
+		}},
+	}
+}
+
+// LoadTransferFunction reads a transfer function saved as JSON by Save.
+func LoadTransferFunction(path string) (TransferFunction, error) {
+	var tf TransferFunction
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tf, err
+	}
+	if err := json.Unmarshal(data, &tf); err != nil {
+		return tf, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(tf.Points) == 0 {
+		return tf, fmt.Errorf("%s: transfer function has no points", path)
+	}
+	sort.SliceStable(tf.Points, func(i, j int) bool { return tf.Points[i].Value < tf.Points[j].Value })
+	return tf, nil
+}
+
+// Save writes tf to path as JSON, for LoadTransferFunction.
+func (tf TransferFunction) Save(path string) error {
+	data, err := json.MarshalIndent(tf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Clone copies tf, so that editing the copy leaves renders of tf in progress
+// alone.
+func (tf TransferFunction) Clone() TransferFunction {
+	tf.Points = append([]TransferPoint(nil), tf.Points...)
+	return tf
+}
+
+// WindowTransfer ramps from transparent black at the bottom of the window to
+// opaque white at the top, so the rendering matches the slices' windowing.
+func WindowTransfer(center float32, width float32) TransferFunction {
+	return TransferFunction{Name: "Window", Points: []TransferPoint{
+		{center - width/2, math32.Color{0, 0, 0}, 0},
+		{center + width/2, math32.Color{1, 1, 1}, 1},
+	}}
+}
+
+// At returns the colour and opacity of value.
+func (tf TransferFunction) At(value float32) (math32.Color, float32) {
+	points := tf.Points
+	if len(points) == 0 {
+		return math32.Color{}, 0
+	}
+	i := sort.Search(len(points), func(i int) bool { return points[i].Value >= value })
+	if i == 0 {
+		return points[0].Color, points[0].Opacity
+	}
+	if i == len(points) {
+		last := points[len(points)-1]
+		return last.Color, last.Opacity
+	}
+	a, b := points[i-1], points[i]
+	t := float32(0)
+	if b.Value > a.Value {
+		t = (value - a.Value) / (b.Value - a.Value)
+	}
+	c := a.Color
+	c.Lerp(&b.Color, t)
+	return c, a.Opacity + (b.Opacity-a.Opacity)*t
+}
+
+// Span is the range of values from the first point to the last.
+func (tf TransferFunction) Span() (float32, float32) {
+	if len(tf.Points) == 0 {
+		return 0, 0
+	}
+	return tf.Points[0].Value, tf.Points[len(tf.Points)-1].Value
+}
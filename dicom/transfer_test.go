@@ -0,0 +1,54 @@
+package volume_test
+
+import (
+	volume "awesomeProject/dicom"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransferFunctionFile(t *testing.T) {
+	dir := t.TempDir()
+	want := volume.TransferPresets(40, 400)[0]
+	path := filepath.Join(dir, "bone.json")
+	if err := want.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := volume.LoadTransferFunction(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != want.Name || len(got.Points) != len(want.Points) {
+		t.Fatalf("read %+v, want %+v", got, want)
+	}
+	for i := range want.Points {
+		if got.Points[i] != want.Points[i] {
+			t.Errorf("point %d = %+v, want %+v", i, got.Points[i], want.Points[i])
+		}
+	}
+
+	// Points written out of order are sorted by value.
+	shuffled := filepath.Join(dir, "shuffled.json")
+	err = os.WriteFile(shuffled, []byte(`{"Name": "x", "Points": [{"Value": 300}, {"Value": -100}, {"Value": 50}]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = volume.LoadTransferFunction(shuffled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, value := range []float32{-100, 50, 300} {
+		if got.Points[i].Value != value {
+			t.Errorf("point %d at %v, want %v", i, got.Points[i].Value, value)
+		}
+	}
+
+	empty := filepath.Join(dir, "empty.json")
+	if err := os.WriteFile(empty, []byte(`{"Name": "x", "Points": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := volume.LoadTransferFunction(empty); err == nil || !strings.Contains(err.Error(), "no points") {
+		t.Errorf("loading a function without points: %v", err)
+	}
+}
@@ -24,6 +24,7 @@ type DicomFile struct {
 type Volume struct {
 	Dicoms  []DicomFile
 	Data    [][][]byte
+	Values  [][][]float32
 	DcmData DcmData
 }
 
@@ -49,14 +50,24 @@ func (v Volume) Render() {
 func New(folderPath string) Volume {
 	dicoms := importDicoms(folderPath)
 	data := make([][][]byte, len(dicoms))
+	values := make([][][]float32, len(dicoms))
 
 	header := readDcmData(dicoms)
 	for i, dcm := range dicoms {
 		dcmInfo, _ := readPixelData(dcm.dataset, tag.PixelData)
-		img, _ := loadFrame(header, dcmInfo)
+		// PET scales every slice on its own.
+		rescale := header
+		if slope, err := readTag(dcm.dataset, tag.RescaleSlope); err == nil {
+			rescale.Slope = slope
+		}
+		if intercept, err := readTag(dcm.dataset, tag.RescaleIntercept); err == nil {
+			rescale.Intercept = intercept
+		}
+		img, rescaled, _ := loadFrame(rescale, dcmInfo)
 		data[i] = img
+		values[i] = rescaled
 	}
-	return Volume{Dicoms: dicoms, Data: data, DcmData: header}
+	return Volume{Dicoms: dicoms, Data: data, Values: values, DcmData: header}
 }
 
 func importDicoms(folderPath string) []DicomFile {
@@ -77,30 +88,59 @@ func importDicoms(folderPath string) []DicomFile {
 	return paths
 }
 
-func loadFrame(data DcmData, pixeldata dicom.PixelDataInfo) ([][]byte, error) {
+func loadFrame(data DcmData, pixeldata dicom.PixelDataInfo) ([][]byte, [][]float32, error) {
 	frame := pixeldata.Frames[0]
 	nativeFrame, _ := frame.GetNativeFrame()
 	imgb := make([][]byte, data.Rows)
+	values := make([][]float32, data.Rows)
 	for i := 0; i < data.Rows; i++ {
 		imgb[i] = make([]byte, data.Cols)
+		values[i] = make([]float32, data.Cols)
 	}
 
 	for i := 0; i < len(nativeFrame.Data); i++ {
 		pixel := float32(nativeFrame.Data[i][0])*data.Slope + data.Intercept
-		pixelByte := uint8(0)
-		if pixel <= data.Window-0.5-(data.Level-1)/2 {
-			pixelByte = 0
-		} else if pixel > data.Window-0.5+(data.Level-1)/2 {
-			pixelByte = 255
-		} else {
-			pixelByte = uint8((((pixel)-((data.Window)-0.5))/(data.Level-1) + 0.5) * (255))
-		}
 		c := i % data.Cols
-		r := i / data.Rows
-		imgb[r][c] = pixelByte
+		r := i / data.Cols
+		imgb[r][c] = data.ToByte(pixel)
+		values[r][c] = pixel
 	}
 
-	return imgb, nil
+	return imgb, values, nil
+}
+
+// FromValues builds a Volume from rescaled values indexed [slice][row][col],
+// windowed through data.
+func FromValues(data DcmData, values [][][]float32) Volume {
+	frames := make([][][]byte, len(values))
+	for z, slice := range values {
+		frames[z] = make([][]byte, len(slice))
+		for r, row := range slice {
+			frames[z][r] = make([]byte, len(row))
+			for c, pixel := range row {
+				frames[z][r][c] = data.ToByte(pixel)
+			}
+		}
+	}
+	return Volume{Data: frames, Values: values, DcmData: data}
+}
+
+// VoxelIndex returns the (col, row, slice) index of the voxel nearest to the
+// patient coordinate p, and whether that voxel lies inside the volume.
+func (volume Volume) VoxelIndex(p *math32.Vector3) ([3]int, bool) {
+	toVoxel := math32.NewMatrix4()
+	toVoxel.GetInverse(volume.DcmData.Calibration)
+	v := math32.NewVec3().Copy(p).ApplyMatrix4(toVoxel)
+	index := [3]int{int(math.Round(float64(v.X))), int(math.Round(float64(v.Y))), int(math.Round(float64(v.Z)))}
+	inside := index[0] >= 0 && index[0] < volume.DcmData.Cols &&
+		index[1] >= 0 && index[1] < volume.DcmData.Rows &&
+		index[2] >= 0 && index[2] < volume.DcmData.Depth
+	return index, inside
+}
+
+// Value returns the rescaled value (HU for CT) of voxel index.
+func (volume Volume) Value(index [3]int) float32 {
+	return volume.Values[index[2]][index[1]][index[0]]
 }
 
 func (volume Volume) GetCorners() AABB {
@@ -120,7 +160,7 @@ func (volume Volume) GetCorners() AABB {
 	corners = append(corners, math32.Vector3{box.Max.X, box.Max.Y, box.Max.Z})
 
 	minX, minY, minZ := float32(math.MaxFloat32), float32(math.MaxFloat32), float32(math.MaxFloat32)
-	maxX, maxY, maxZ := float32(math.SmallestNonzeroFloat32), float32(math.SmallestNonzeroFloat32), float32(math.SmallestNonzeroFloat32)
+	maxX, maxY, maxZ := float32(-math.MaxFloat32), float32(-math.MaxFloat32), float32(-math.MaxFloat32)
 
 	calibratedCorners := []math32.Vector3{}
 	for i := 0; i < len(corners); i++ {
@@ -156,3 +196,25 @@ func (volume Volume) GetCorners() AABB {
 		Box:               calibratedBox,
 	}
 }
+
+// SOPInstanceUIDs returns the SOP Instance UID of each slice, in slice order.
+func (volume Volume) SOPInstanceUIDs() []string {
+	uids := make([]string, len(volume.Dicoms))
+	for i, dcm := range volume.Dicoms {
+		uids[i] = stringOf(dcm.dataset, tag.SOPInstanceUID)
+	}
+	return uids
+}
+
+// SetWindow re-windows Data from Values with the given centre and width.
+func (volume *Volume) SetWindow(center float32, width float32) {
+	volume.DcmData.Window = center
+	volume.DcmData.Level = width
+	for z, slice := range volume.Values {
+		for r, row := range slice {
+			for c, pixel := range row {
+				volume.Data[z][r][c] = volume.DcmData.ToByte(pixel)
+			}
+		}
+	}
+}
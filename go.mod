@@ -2,11 +2,13 @@ module awesomeProject
 
 go 1.19
 
-require github.com/suyashkumar/dicom v1.0.5
+require (
+	github.com/g3n/engine v0.2.0
+	github.com/suyashkumar/dicom v1.0.5
+)
 
 require (
 	github.com/g3n/demos/hellog3n v0.0.0-20220618220802-541b62abcc93 // indirect
-	github.com/g3n/engine v0.2.0 // indirect
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20221017161538-93cebf72946b // indirect
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	golang.org/x/image v0.2.0 // indirect
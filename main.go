@@ -5,16 +5,272 @@ import (
 	"awesomeProject/threeD"
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 func main() {
 	var dcmPath = flag.String("dcm", "", "Dicom Path")
+	var srPath = flag.String("sr", "", "Measurement report (DICOM SR) path, defaults to <dcm>-sr.dcm beside the series")
+	var sessionPath = flag.String("session", "", "Session file to restore and save to, defaults to <dcm>-session.json beside the series")
+	var keysPath = flag.String("keys", "", "Key binding config file (JSON), defaults built in")
+	var captureDir = flag.String("capture-dir", ".", "Directory for captured PNGs")
+	var captureSize = flag.String("capture-size", "1920x1080", "Capture resolution, WIDTHxHEIGHT")
+	var cinePath = flag.String("cine", "", "Export a cine to this .gif, or PNG frames to this directory, and exit; without it the viewer's Cine button writes GIFs to -capture-dir")
+	var cineMode = flag.String("cine-mode", "scroll", "Cine sweep: scroll, rotate or slab")
+	var cineAxis = flag.String("cine-axis", "z", "Volume axis to scroll along, rotate around or move the slab along: x, y or z")
+	var cineRange = flag.String("cine-range", "", "Cine range FROM:TO in slices, degrees or mm from the centre, defaults to everything")
+	var cineStep = flag.Float64("cine-step", 0, "Cine step in slices, degrees or mm, defaults to one slice, degree or voxel")
+	var cineThickness = flag.Float64("cine-thickness", 10, "Slab thickness in mm")
+	var cineWindow = flag.String("cine-window", "", "Cine window CENTER,WIDTH, defaults to the series' window")
+	var cineFPS = flag.Float64("cine-fps", 10, "Cine GIF frame rate")
+	var cineProjection = flag.String("cine-projection", "none", "Project rotating and slab frames: none, mip, minip or average")
+	var cineSize = flag.Int("cine-size", 256, "Width in pixels of rotating and slab frames")
+	var transferPath = flag.String("transfer", "", "Volume rendering transfer function (JSON), defaults to <dcm>-transfer.json beside the series")
+	var surfacePath = flag.String("surface", "", "Extract a surface to this .stl, .obj or .ply and exit; without it the viewer's Surface button shows one and Export writes it to -capture-dir")
+	var surfaceThreshold = flag.Float64("surface-threshold", 300, "Surface threshold, HU for CT")
+	var surfaceMask = flag.String("surface-mask", "", "Label mask series to extract the surface of instead of thresholding")
+	var surfaceLabel = flag.Float64("surface-label", 0, "Mask label to extract, 0 for every non-zero voxel")
+	var surfaceSmooth = flag.Int("surface-smooth", 10, "Surface smoothing iterations")
+	var surfaceDecimate = flag.Float64("surface-decimate", 0, "Merge surface vertices closer than this many mm, 0 to keep them all")
+	var surfaceFormat = flag.String("surface-format", "stl", "Format of surfaces exported from the viewer: stl, obj or ply")
+	var lutName = flag.String("lut", "", "Colour the images with a lookup table: gray, inverse gray, hot iron, rainbow, jet, pet, or a table file")
+	var fusePath = flag.String("fuse", "", "Secondary series, PET say, to blend over the images; it must share the patient coordinates")
+	var fuseLUT = flag.String("fuse-lut", "hot iron", "Lookup table of the secondary series")
+	var fuseOpacity = flag.Float64("fuse-opacity", 0.5, "Opacity of the secondary series, 0 to 1")
+	var fuseWindow = flag.String("fuse-window", "", "Secondary window CENTER,WIDTH, defaults to the series' window")
+	var registrationPath = flag.String("registration", "", "Registration of the -fuse series, a DICOM spatial registration (.dcm) or a text matrix, applied when it exists and saved to by the Register button; defaults to <fuse>-registration.dcm beside the series")
+	var registerPath = flag.String("register", "", "Register the -fuse series onto -dcm, save the matrix to this .dcm or text file and exit")
+	var registerTransform = flag.String("register-transform", "rigid", "Registration transform: rigid, similarity or affine")
+	var registerMetric = flag.String("register-metric", "mi", "Registration metric: mi (mutual information) or ncc (cross-correlation)")
+	var registerLevels = flag.Int("register-levels", 3, "Registration resolutions, each half the next")
+	var valueMode = flag.String("values", "raw", "Show PET values as raw activity or as SUVbw, SUVlbm or SUVbsa")
+	var fuseValues = flag.String("fuse-values", "raw", "Show the secondary series' PET values as raw activity or as SUVbw, SUVlbm or SUVbsa")
 	flag.Parse()
 
+	capture := threeD.CaptureRequest{Dir: *captureDir, Overlays: true}
+	if _, err := fmt.Sscanf(*captureSize, "%dx%d", &capture.Width, &capture.Height); err != nil {
+		fmt.Println("Error: capture size must look like 1920x1080")
+		return
+	}
+
+	cine, err := parseCine(*cineMode, *cineAxis, *cineRange, *cineWindow, *cineProjection)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	cine.Step = float32(*cineStep)
+	cine.Thickness = float32(*cineThickness)
+	cine.Size = *cineSize
+	if *cinePath != "" {
+		if *dcmPath == "" {
+			fmt.Println("Error: you must provide a valid path")
+			return
+		}
+		frames, err := cine.Frames(volume.New(*dcmPath))
+		if err == nil {
+			_, err = volume.WriteCine(*cinePath, frames, float32(*cineFPS))
+		}
+		if err != nil {
+			fmt.Println("Error: cine export:", err)
+			return
+		}
+		fmt.Printf("wrote %d frames to %s\n", len(frames), *cinePath)
+		return
+	}
+
+	var registration volume.Registration
+	if registration.Kind, err = volume.ParseTransformKind(*registerTransform); err == nil {
+		registration.Metric, err = volume.ParseMetric(*registerMetric)
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	registration.Levels = *registerLevels
+	if *registerPath != "" {
+		if *dcmPath == "" || *fusePath == "" {
+			fmt.Println("Error: registration needs -dcm and -fuse")
+			return
+		}
+		fixed, moving := volume.New(*dcmPath), volume.New(*fusePath)
+		m, score, err := registration.Register(fixed, moving)
+		if err == nil {
+			err = volume.WriteRegistration(*registerPath, fixed, moving, m, registration.Kind)
+		}
+		if err != nil {
+			fmt.Println("Error: registration:", err)
+			return
+		}
+		fmt.Printf("registered with %s %.4g, wrote %s\n", registration.Metric, score, *registerPath)
+		return
+	}
+
+	surface := threeD.SurfaceRequest{Threshold: float32(*surfaceThreshold), Label: float32(*surfaceLabel), Smooth: *surfaceSmooth,
+		Decimate: float32(*surfaceDecimate), Dir: *captureDir, Format: strings.ToLower(*surfaceFormat)}
+	if *surfaceMask != "" {
+		mask := volume.New(*surfaceMask)
+		surface.Mask = &mask
+	}
+	if *surfacePath != "" {
+		if *dcmPath == "" {
+			fmt.Println("Error: you must provide a valid path")
+			return
+		}
+		mesh := surface.Extract(volume.New(*dcmPath))
+		if err := volume.WriteMesh(*surfacePath, mesh); err != nil {
+			fmt.Println("Error: surface export:", err)
+			return
+		}
+		fmt.Printf("wrote %d triangles enclosing %.0f mm³ to %s\n", len(mesh.Indices)/3, mesh.Volume(), *surfacePath)
+		return
+	}
+
+	var session *threeD.Session
+	if *sessionPath != "" {
+		if s, err := threeD.LoadSession(*sessionPath); err == nil {
+			session = &s
+			if *dcmPath == "" {
+				*dcmPath = s.Input
+			} else if !samePath(*dcmPath, s.Input) {
+				// Slices and measurements of another series would land
+				// anywhere on this one.
+				fmt.Printf("Warning: session %s is of %s, not %s; starting afresh\n", *sessionPath, s.Input, *dcmPath)
+				session = nil
+			}
+		} else if !os.IsNotExist(err) {
+			fmt.Println("Error: cannot read session:", err)
+			return
+		}
+	}
 	if *dcmPath == "" {
 		fmt.Println("Error: you must provide a valid path")
 		return
 	}
+	if *srPath == "" {
+		*srPath = filepath.Clean(*dcmPath) + "-sr.dcm"
+	}
+	if *sessionPath == "" {
+		*sessionPath = filepath.Clean(*dcmPath) + "-session.json"
+	}
+	if *transferPath == "" {
+		*transferPath = filepath.Clean(*dcmPath) + "-transfer.json"
+	}
+	var lut *volume.LUT
+	if *lutName != "" {
+		if lut, err = volume.BuiltinLUT(*lutName); err != nil {
+			if lut, err = volume.LoadLUT(*lutName); err != nil {
+				fmt.Println("Error: lookup table:", err)
+				return
+			}
+		}
+	}
+	values, err := volume.ParseValueMode(*valueMode)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	var fusion *threeD.Fusion
+	if *fusePath != "" {
+		secondary := volume.New(*fusePath)
+		if mode, err := volume.ParseValueMode(*fuseValues); err != nil {
+			fmt.Println("Error:", err)
+			return
+		} else if mode != volume.RawValues {
+			p, err := secondary.SUV()
+			if err == nil {
+				secondary, err = secondary.InMode(p, mode)
+			}
+			if err != nil {
+				fmt.Println("Error: secondary values:", err)
+				return
+			}
+		}
+		if *registrationPath == "" {
+			*registrationPath = filepath.Clean(*fusePath) + "-registration.dcm"
+		}
+		fusion = &threeD.Fusion{Volume: secondary, Opacity: float32(*fuseOpacity), Enabled: true,
+			Registration: registration, RegistrationPath: *registrationPath}
+		if _, err := os.Stat(*registrationPath); err == nil {
+			m, err := volume.ReadRegistration(*registrationPath, fusion.Volume)
+			if err != nil {
+				fmt.Println("Error: registration:", err)
+				return
+			}
+			fusion.Volume, fusion.Matrix = fusion.Volume.Transformed(m), m
+		}
+		data := &fusion.Volume.DcmData
+		if data.LUT, err = volume.BuiltinLUT(*fuseLUT); err != nil {
+			if data.LUT, err = volume.LoadLUT(*fuseLUT); err != nil {
+				fmt.Println("Error: lookup table:", err)
+				return
+			}
+		}
+		if *fuseWindow != "" {
+			if _, err := fmt.Sscanf(*fuseWindow, "%g,%g", &data.Window, &data.Level); err != nil || data.Level <= 0 {
+				fmt.Println("Error: fusion window must look like 3,6")
+				return
+			}
+		}
+	}
+	keys := threeD.DefaultKeyConfig()
+	if *keysPath != "" {
+		var err error
+		if keys, err = threeD.LoadKeyConfig(*keysPath); err != nil {
+			fmt.Println("Error: cannot read key bindings:", err)
+			return
+		}
+	}
+	input, err := filepath.Abs(*dcmPath)
+	if err != nil {
+		input = *dcmPath
+	}
 	volume := volume.New(*dcmPath)
-	threeD.Init(volume)
+	threeD.Init(volume, threeD.Options{Input: input, SRPath: *srPath, SessionPath: *sessionPath, Session: session, Keys: keys, Capture: capture,
+		Cine: threeD.CineRequest{Cine: cine, Dir: *captureDir, FPS: float32(*cineFPS)}, TransferPath: *transferPath, Surface: surface, LUT: lut, Fusion: fusion, Values: values})
+}
+
+// parseCine reads the cine flags that are not plain numbers.
+func parseCine(mode string, axis string, span string, window string, projection string) (volume.Cine, error) {
+	var c volume.Cine
+	var err error
+	if c.Mode, err = volume.ParseCineMode(mode); err != nil {
+		return c, err
+	}
+	if c.Projection, err = volume.ParseProjection(projection); err != nil {
+		return c, err
+	}
+	switch strings.ToLower(axis) {
+	case "x":
+		c.Axis = 0
+	case "y":
+		c.Axis = 1
+	case "z":
+		c.Axis = 2
+	default:
+		return c, fmt.Errorf("cine axis must be x, y or z")
+	}
+	if span != "" {
+		if _, err := fmt.Sscanf(span, "%g:%g", &c.Range[0], &c.Range[1]); err != nil {
+			return c, fmt.Errorf("cine range must look like 0:180")
+		}
+	}
+	if window != "" {
+		if _, err := fmt.Sscanf(window, "%g,%g", &c.WindowCenter, &c.WindowWidth); err != nil || c.WindowWidth <= 0 {
+			return c, fmt.Errorf("cine window must look like 40,400")
+		}
+	}
+	return c, nil
+}
+
+// samePath reports whether a and b name the same file once made absolute.
+func samePath(a string, b string) bool {
+	if abs, err := filepath.Abs(a); err == nil {
+		a = abs
+	}
+	if abs, err := filepath.Abs(b); err == nil {
+		b = abs
+	}
+	return a == b
 }
@@ -0,0 +1,93 @@
+package phantom
+
+import (
+	volume "awesomeProject/dicom"
+
+	"github.com/g3n/engine/math32"
+)
+
+// Phantom describes a synthetic volume: a voxel grid placed in patient space
+// and a list of shapes evaluated at every voxel center.
+type Phantom struct {
+	Cols       int
+	Rows       int
+	Depth      int
+	Spacing    *math32.Vector3
+	Origin     *math32.Vector3
+	RowDir     *math32.Vector3
+	ColDir     *math32.Vector3
+	Window     float32
+	Level      float32
+	Background float32
+	Shapes     []Shape
+}
+
+// New returns an axial phantom with isotropic spacing, its first voxel at the
+// patient origin and a window covering -1000..1000.
+func New(cols int, rows int, depth int, spacing float32, shapes ...Shape) *Phantom {
+	return &Phantom{
+		Cols:       cols,
+		Rows:       rows,
+		Depth:      depth,
+		Spacing:    math32.NewVector3(spacing, spacing, spacing),
+		Origin:     math32.NewVec3(),
+		RowDir:     math32.NewVector3(1, 0, 0),
+		ColDir:     math32.NewVector3(0, 1, 0),
+		Window:     0,
+		Level:      2000,
+		Background: -1000,
+		Shapes:     shapes,
+	}
+}
+
+func (p *Phantom) orientation() *math32.Matrix4 {
+	dirX := math32.NewVec3().Copy(p.RowDir).Normalize()
+	dirY := math32.NewVec3().Copy(p.ColDir).Normalize()
+	dirZ := math32.NewVec3().CrossVectors(dirX, dirY).Normalize()
+	return math32.NewMatrix4().MakeBasis(dirX, dirY, dirZ)
+}
+
+// Data returns the header the phantom would have once loaded from disk.
+func (p *Phantom) Data() volume.DcmData {
+	data := volume.NewDcmData(p.Rows, p.Cols, p.Depth, p.orientation(), math32.NewVec3().Copy(p.Origin), math32.NewVec3().Copy(p.Spacing))
+	data.Window = p.Window
+	data.Level = p.Level
+	data.Units = "HU"
+	return data
+}
+
+// VoxelCenter returns the patient coordinate of voxel (col, row, slice).
+func (p *Phantom) VoxelCenter(col int, row int, slice int) *math32.Vector3 {
+	return math32.NewVector3(float32(col), float32(row), float32(slice)).ApplyMatrix4(p.Data().Calibration)
+}
+
+// ValueAt evaluates the shapes, in order, at a patient coordinate.
+func (p *Phantom) ValueAt(pt *math32.Vector3) float32 {
+	value := p.Background
+	for _, shape := range p.Shapes {
+		value = shape.Apply(pt, value)
+	}
+	return value
+}
+
+// Values samples the phantom at every voxel center, indexed [slice][row][col].
+func (p *Phantom) Values() [][][]float32 {
+	cal := p.Data().Calibration
+	values := make([][][]float32, p.Depth)
+	for z := 0; z < p.Depth; z++ {
+		values[z] = make([][]float32, p.Rows)
+		for r := 0; r < p.Rows; r++ {
+			values[z][r] = make([]float32, p.Cols)
+			for c := 0; c < p.Cols; c++ {
+				pt := math32.NewVector3(float32(c), float32(r), float32(z)).ApplyMatrix4(cal)
+				values[z][r][c] = p.ValueAt(pt)
+			}
+		}
+	}
+	return values
+}
+
+// Volume samples the phantom into an in-memory Volume.
+func (p *Phantom) Volume() volume.Volume {
+	return volume.FromValues(p.Data(), p.Values())
+}
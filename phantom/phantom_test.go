@@ -0,0 +1,69 @@
+package phantom
+
+import (
+	volume "awesomeProject/dicom"
+	"testing"
+
+	"github.com/g3n/engine/math32"
+)
+
+func TestSphereValues(t *testing.T) {
+	p := New(16, 16, 16, 2, Sphere{Center: math32.NewVector3(15, 15, 15), Radius: 6, Value: 500})
+	v := p.Volume()
+
+	inside := p.Data().ToByte(500)
+	outside := p.Data().ToByte(-1000)
+	if got := v.Data[7][7][7]; got != inside {
+		t.Errorf("center voxel = %d, want %d", got, inside)
+	}
+	if got := v.Data[0][0][0]; got != outside {
+		t.Errorf("corner voxel = %d, want %d", got, outside)
+	}
+	if got := p.ValueAt(p.VoxelCenter(7, 7, 10)); got != 500 {
+		t.Errorf("value 5mm from center = %v, want 500", got)
+	}
+	if got := p.ValueAt(p.VoxelCenter(7, 7, 11)); got != -1000 {
+		t.Errorf("value 7mm from center = %v, want -1000", got)
+	}
+}
+
+func TestObliqueGeometry(t *testing.T) {
+	p := New(8, 6, 4, 1)
+	p.Spacing = math32.NewVector3(0.5, 0.75, 2)
+	p.Origin = math32.NewVector3(-10, 20, 30)
+	p.RowDir = math32.NewVector3(0, 1, 0)
+	p.ColDir = math32.NewVector3(0, 0, -1)
+
+	got := p.VoxelCenter(2, 4, 1)
+	want := math32.NewVector3(-10-2, 20+2*0.5, 30-4*0.75)
+	if got.DistanceTo(want) > 1e-4 {
+		t.Errorf("voxel center = %v, want %v", got, want)
+	}
+}
+
+func TestWriteSeries(t *testing.T) {
+	p := New(12, 10, 5, 1.5,
+		Gradient{Origin: math32.NewVec3(), Direction: math32.NewVector3(1, 0, 0), Start: -900, Slope: 100})
+	dir := t.TempDir()
+	if err := p.WriteSeries(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	v := volume.New(dir)
+	want := p.Volume()
+	if v.DcmData.Rows != 10 || v.DcmData.Cols != 12 || v.DcmData.Depth != 5 {
+		t.Fatalf("size = %dx%dx%d, want 12x10x5", v.DcmData.Cols, v.DcmData.Rows, v.DcmData.Depth)
+	}
+	if !v.DcmData.VoxelSize.Equals(p.Spacing) {
+		t.Errorf("voxel size = %v, want %v", v.DcmData.VoxelSize, p.Spacing)
+	}
+	for z := range want.Data {
+		for r := range want.Data[z] {
+			for c := range want.Data[z][r] {
+				if v.Data[z][r][c] != want.Data[z][r][c] {
+					t.Fatalf("voxel (%d,%d,%d) = %d, want %d", c, r, z, v.Data[z][r][c], want.Data[z][r][c])
+				}
+			}
+		}
+	}
+}
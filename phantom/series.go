@@ -0,0 +1,135 @@
+package phantom
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/g3n/engine/math32"
+	"github.com/suyashkumar/dicom"
+	"github.com/suyashkumar/dicom/pkg/frame"
+	"github.com/suyashkumar/dicom/pkg/tag"
+	"github.com/suyashkumar/dicom/pkg/uid"
+)
+
+const (
+	ctImageStorage = "1.2.840.10008.5.1.4.1.1.2"
+	rescaleOffset  = -1024
+)
+
+// WriteSeries writes the phantom as a CT series, one file per slice, into dir.
+// Values are stored as unsigned 16 bit with a -1024 rescale intercept.
+func (p *Phantom) WriteSeries(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	studyUID, err := newUID()
+	if err != nil {
+		return err
+	}
+	seriesUID, err := newUID()
+	if err != nil {
+		return err
+	}
+	frameUID, err := newUID()
+	if err != nil {
+		return err
+	}
+
+	data := p.Data()
+	values := p.Values()
+	for z, slice := range values {
+		sopUID, err := newUID()
+		if err != nil {
+			return err
+		}
+		position := math32.NewVector3(0, 0, float32(z)).ApplyMatrix4(data.Calibration)
+		pixels := make([][]int, 0, p.Rows*p.Cols)
+		for _, row := range slice {
+			for _, value := range row {
+				stored := math.Round(float64(value - rescaleOffset))
+				stored = math.Max(0, math.Min(math.MaxUint16, stored))
+				pixels = append(pixels, []int{int(stored)})
+			}
+		}
+		pixelData := dicom.PixelDataInfo{Frames: []frame.Frame{{
+			NativeData: frame.NativeFrame{Data: pixels, Rows: p.Rows, Cols: p.Cols, BitsPerSample: 16},
+		}}}
+
+		elements := []struct {
+			t     tag.Tag
+			value interface{}
+		}{
+			{tag.MediaStorageSOPClassUID, []string{ctImageStorage}},
+			{tag.MediaStorageSOPInstanceUID, []string{sopUID}},
+			{tag.TransferSyntaxUID, []string{uid.ExplicitVRLittleEndian}},
+			{tag.SOPClassUID, []string{ctImageStorage}},
+			{tag.SOPInstanceUID, []string{sopUID}},
+			{tag.Modality, []string{"CT"}},
+			{tag.PatientName, []string{"Phantom"}},
+			{tag.PatientID, []string{"PHANTOM"}},
+			{tag.StudyInstanceUID, []string{studyUID}},
+			{tag.SeriesInstanceUID, []string{seriesUID}},
+			{tag.InstanceNumber, []string{strconv.Itoa(z + 1)}},
+			{tag.ImagePositionPatient, vectorStrings(position)},
+			{tag.ImageOrientationPatient, append(vectorStrings(p.RowDir), vectorStrings(p.ColDir)...)},
+			{tag.FrameOfReferenceUID, []string{frameUID}},
+			{tag.SliceThickness, []string{formatFloat(p.Spacing.Z)}},
+			{tag.SamplesPerPixel, []int{1}},
+			{tag.PhotometricInterpretation, []string{"MONOCHROME2"}},
+			{tag.Rows, []int{p.Rows}},
+			{tag.Columns, []int{p.Cols}},
+			{tag.PixelSpacing, []string{formatFloat(p.Spacing.X), formatFloat(p.Spacing.Y)}},
+			{tag.BitsAllocated, []int{16}},
+			{tag.BitsStored, []int{16}},
+			{tag.HighBit, []int{15}},
+			{tag.PixelRepresentation, []int{0}},
+			{tag.WindowCenter, []string{formatFloat(p.Window)}},
+			{tag.WindowWidth, []string{formatFloat(p.Level)}},
+			{tag.RescaleIntercept, []string{formatFloat(rescaleOffset)}},
+			{tag.RescaleSlope, []string{"1"}},
+			{tag.PixelData, pixelData},
+		}
+		ds := dicom.Dataset{}
+		for _, e := range elements {
+			element, err := dicom.NewElement(e.t, e.value)
+			if err != nil {
+				return fmt.Errorf("%v: %w", e.t, err)
+			}
+			ds.Elements = append(ds.Elements, element)
+		}
+
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("slice_%04d.dcm", z)))
+		if err != nil {
+			return err
+		}
+		err = dicom.Write(f, ds)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func vectorStrings(v *math32.Vector3) []string {
+	return []string{formatFloat(v.X), formatFloat(v.Y), formatFloat(v.Z)}
+}
+
+func formatFloat(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
+
+func newUID() (string, error) {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", err
+	}
+	return "2.25." + n.String(), nil
+}
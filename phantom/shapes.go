@@ -0,0 +1,163 @@
+package phantom
+
+import (
+	"math"
+
+	"github.com/g3n/engine/math32"
+)
+
+// Shape updates the value of a patient coordinate. Shapes are applied in
+// order, so later shapes paint over or add to earlier ones.
+type Shape interface {
+	Apply(pt *math32.Vector3, value float32) float32
+}
+
+// Sphere fills a ball with Value.
+type Sphere struct {
+	Center *math32.Vector3
+	Radius float32
+	Value  float32
+}
+
+func (s Sphere) Apply(pt *math32.Vector3, value float32) float32 {
+	if pt.DistanceTo(s.Center) <= s.Radius {
+		return s.Value
+	}
+	return value
+}
+
+// Cylinder fills a capped cylinder centred on Center, running Height mm along Axis.
+type Cylinder struct {
+	Center *math32.Vector3
+	Axis   *math32.Vector3
+	Radius float32
+	Height float32
+	Value  float32
+}
+
+func (c Cylinder) Apply(pt *math32.Vector3, value float32) float32 {
+	axis := math32.NewVec3().Copy(c.Axis).Normalize()
+	d := math32.NewVec3().SubVectors(pt, c.Center)
+	along := d.Dot(axis)
+	if math32.Abs(along) > c.Height/2 {
+		return value
+	}
+	radial := d.Sub(axis.MultiplyScalar(along))
+	if radial.Length() <= c.Radius {
+		return c.Value
+	}
+	return value
+}
+
+// Gradient replaces the value with Start + Slope * distance from Origin along Direction.
+type Gradient struct {
+	Origin    *math32.Vector3
+	Direction *math32.Vector3
+	Start     float32
+	Slope     float32
+}
+
+func (g Gradient) Apply(pt *math32.Vector3, value float32) float32 {
+	dir := math32.NewVec3().Copy(g.Direction).Normalize()
+	d := math32.NewVec3().SubVectors(pt, g.Origin)
+	return g.Start + g.Slope*d.Dot(dir)
+}
+
+// Grid paints planes of thickness Width every Spacing mm along the patient axes.
+type Grid struct {
+	Origin  *math32.Vector3
+	Spacing float32
+	Width   float32
+	Value   float32
+}
+
+func (g Grid) Apply(pt *math32.Vector3, value float32) float32 {
+	d := math32.NewVec3().SubVectors(pt, g.Origin)
+	for i := 0; i < 3; i++ {
+		m := math.Mod(float64(d.Component(i)), float64(g.Spacing))
+		if m < 0 {
+			m += float64(g.Spacing)
+		}
+		if m < float64(g.Width)/2 || float64(g.Spacing)-m <= float64(g.Width)/2 {
+			return g.Value
+		}
+	}
+	return value
+}
+
+// Checkerboard alternates Low and High in cubes of Size mm.
+type Checkerboard struct {
+	Origin *math32.Vector3
+	Size   float32
+	Low    float32
+	High   float32
+}
+
+func (c Checkerboard) Apply(pt *math32.Vector3, value float32) float32 {
+	d := math32.NewVec3().SubVectors(pt, c.Origin)
+	parity := 0
+	for i := 0; i < 3; i++ {
+		parity += int(math.Floor(float64(d.Component(i) / c.Size)))
+	}
+	if parity%2 == 0 {
+		return c.Low
+	}
+	return c.High
+}
+
+type ellipsoid struct {
+	intensity  float32
+	a, b, c    float32
+	x0, y0, z0 float32
+	phi, theta float32
+	psi        float32
+}
+
+// sheppLogan lists the ellipsoids of the modified 3D Shepp-Logan phantom in
+// normalised coordinates, angles in degrees.
+var sheppLogan = []ellipsoid{
+	{1, .6900, .920, .810, 0, 0, 0, 0, 0, 0},
+	{-.8, .6624, .874, .780, 0, -.0184, 0, 0, 0, 0},
+	{-.2, .1100, .310, .220, .22, 0, 0, -18, 0, 10},
+	{-.2, .1600, .410, .280, -.22, 0, 0, 18, 0, 10},
+	{.1, .2100, .250, .410, 0, .35, -.15, 0, 0, 0},
+	{.1, .0460, .046, .050, 0, .1, .25, 0, 0, 0},
+	{.1, .0460, .046, .050, 0, -.1, .25, 0, 0, 0},
+	{.1, .0460, .023, .050, -.08, -.605, 0, 0, 0, 0},
+	{.1, .0230, .023, .020, 0, -.606, 0, 0, 0, 0},
+	{.1, .0230, .046, .020, .06, -.605, 0, 0, 0, 0},
+}
+
+// SheppLogan adds the modified 3D Shepp-Logan phantom, scaled so that the unit
+// cube spans HalfSize mm on each side of Center and unit intensity equals Scale.
+type SheppLogan struct {
+	Center   *math32.Vector3
+	HalfSize *math32.Vector3
+	Scale    float32
+}
+
+func (s SheppLogan) Apply(pt *math32.Vector3, value float32) float32 {
+	x := (pt.X - s.Center.X) / s.HalfSize.X
+	y := (pt.Y - s.Center.Y) / s.HalfSize.Y
+	z := (pt.Z - s.Center.Z) / s.HalfSize.Z
+	for _, e := range sheppLogan {
+		phi := e.phi * math.Pi / 180
+		theta := e.theta * math.Pi / 180
+		psi := e.psi * math.Pi / 180
+		cphi, sphi := math32.Cos(phi), math32.Sin(phi)
+		ctheta, stheta := math32.Cos(theta), math32.Sin(theta)
+		cpsi, spsi := math32.Cos(psi), math32.Sin(psi)
+
+		xp := (cpsi*cphi-ctheta*sphi*spsi)*x + (cpsi*sphi+ctheta*cphi*spsi)*y + spsi*stheta*z
+		yp := (-spsi*cphi-ctheta*sphi*cpsi)*x + (-spsi*sphi+ctheta*cphi*cpsi)*y + cpsi*stheta*z
+		zp := stheta*sphi*x - stheta*cphi*y + ctheta*z
+
+		dx := (xp - e.x0) / e.a
+		dy := (yp - e.y0) / e.b
+		dz := (zp - e.z0) / e.c
+		if dx*dx+dy*dy+dz*dz <= 1 {
+			value += e.intensity * s.Scale
+		}
+	}
+	return value
+}
@@ -3,6 +3,8 @@ package threeD
 import (
 	volume "awesomeProject/dicom"
 	"fmt"
+	"log"
+	"os"
 	"time"
 
 	"github.com/g3n/engine/app"
@@ -23,40 +25,77 @@ import (
 
 type CutCallback func(float32, volume.Volume)
 
+var obliqueColor = math32.NewColor("magenta")
+
 type GuiState struct {
-	Debug         bool
-	Dirty         bool
-	Slice         *math32.Vector3
-	AxialNode     *core.Node
-	CoronalNode   *core.Node
-	SagittallNode *core.Node
-	CustomNode    *core.Node
-	DebugNode     *core.Node
-	Axial         volume.SliceFrame
-	Coronal       volume.SliceFrame
-	Sagittal      volume.SliceFrame
-	Custom        volume.SliceFrame
+	Debug          bool
+	Dirty          bool
+	Slice          *math32.Vector3
+	AxialNode      *core.Node
+	CoronalNode    *core.Node
+	SagittallNode  *core.Node
+	CustomNode     *core.Node
+	DebugNode      *core.Node
+	Axial          volume.SliceFrame
+	Coronal        volume.SliceFrame
+	Sagittal       volume.SliceFrame
+	Custom         volume.SliceFrame
+	AxialView      *Viewport2D
+	CoronalView    *Viewport2D
+	SagittalView   *Viewport2D
+	XSlider        *gui.Slider
+	YSlider        *gui.Slider
+	ZSlider        *gui.Slider
+	cut            *math32.Vector3
+	navigating     *Viewport2D
+	syncing        bool
+	panning        *Viewport2D
+	Measurements   []volume.Measurement
+	Measuring      Measuring
+	Landmarks      Landmarks
+	Oblique        *math32.Matrix4
+	DebugBox       *gui.CheckRadio
+	History        History
+	pendingCapture *CaptureRequest
+	DVR            *dvrView
+	Transfer       *transferEditor
+	Surface        *surfaceView
+	Clip           *clipState
+	LUTMenu        *gui.DropDown
+	luts           []*volume.LUT
+	values         *valueModes
+	Fusion         *Fusion
+}
+
+// Views returns the 2D viewports in axial, coronal, sagittal order.
+func (g *GuiState) Views() []*Viewport2D {
+	return []*Viewport2D{g.AxialView, g.CoronalView, g.SagittalView}
 }
 
 func updateAxial(g *GuiState, v volume.Volume) {
 	g.Axial = volume.Axial(v, int(g.Slice.Z))
 	g.Axial.Cut(v)
+	g.fuse(g.Axial)
 }
 func updateSagittal(g *GuiState, v volume.Volume) {
 	g.Sagittal = volume.Sagittal(v, int(g.Slice.X))
 	g.Sagittal.Cut(v)
+	g.fuse(g.Sagittal)
 }
 func updateCoronal(g *GuiState, v volume.Volume) {
 	g.Coronal = volume.Coronal(v, int(g.Slice.Y))
 	g.Coronal.Cut(v)
+	g.fuse(g.Coronal)
 }
 
 func updateFree(g *GuiState, v volume.Volume) {
-	g.Custom = volume.FreeRotation(v, math32.NewMatrix4())
+	g.Custom = volume.FreeRotation(v, g.Oblique)
 	g.Custom.Cut(v)
+	g.fuse(g.Custom)
+	g.CustomNode = Draw(g.Custom, v, g.CustomNode, obliqueColor)
 }
 
-func placeSliderButton(scene *core.Node,
+func placeSliderButton(scene *gui.Panel,
 	x float32,
 	y float32,
 	normalizedValue float32,
@@ -82,37 +121,33 @@ func norm(v float32, max float32) float32 {
 func denorm(v float32, max float32) float32 {
 	return v * max
 }
-func placeButtons(scene *core.Node, labels []string, g *GuiState, v volume.Volume) {
+func placeButtons(scene *gui.Panel, labels []string, g *GuiState, v volume.Volume) {
 
-	sagBtn := placeSliderButton(scene, 10, 0, norm(g.Slice.Y, float32(v.DcmData.Cols)), v, float32(v.DcmData.Cols), func(f float32, v volume.Volume) {
-		g.Slice.X = f
-		g.Dirty = true
+	sagBtn := placeSliderButton(scene, 10, 0, norm(g.Slice.X, float32(v.DcmData.Cols)), v, float32(v.DcmData.Cols), func(f float32, v volume.Volume) {
+		g.slide(func() { g.Slice.X = f })
 	})
 
 	cornBtn := placeSliderButton(scene, 10, 30, norm(g.Slice.Y, float32(v.DcmData.Rows)), v, float32(v.DcmData.Rows), func(f float32, v volume.Volume) {
-		g.Slice.Y = f
-		g.Dirty = true
+		g.slide(func() { g.Slice.Y = f })
 	})
 
 	axialBtn := placeSliderButton(scene, 10, 60, norm(g.Slice.Z, float32(v.DcmData.Depth)), v, float32(v.DcmData.Depth), func(f float32, v volume.Volume) {
-		g.Slice.Z = f
-		g.Dirty = true
+		g.slide(func() { g.Slice.Z = f })
 	})
 
 	resetBtn := gui.NewButton("Reset")
 	resetBtn.SetPosition(10, 90)
 	resetBtn.Subscribe(gui.OnClick, func(name string, ev interface{}) {
-		g.Slice.X = float32(v.DcmData.Cols / 2)
-		sagBtn.SetValue(norm(g.Slice.X, float32(v.DcmData.Cols)))
-		g.Slice.Y = float32(v.DcmData.Rows / 2)
-		cornBtn.SetValue(norm(g.Slice.Y, float32(v.DcmData.Rows)))
-		g.Slice.Z = float32(v.DcmData.Depth / 2)
-		axialBtn.SetValue(norm(g.Slice.Z, float32(v.DcmData.Depth)))
-		updateAxial(g, v)
-		updateCoronal(g, v)
-		updateSagittal(g, v)
+		g.reset(v)
 	})
 	scene.Add(resetBtn)
+	g.XSlider, g.YSlider, g.ZSlider = sagBtn, cornBtn, axialBtn
+	// A slider drag undoes as one move.
+	for _, s := range []*gui.Slider{sagBtn, cornBtn, axialBtn} {
+		s.Subscribe(gui.OnMouseDown, func(name string, ev interface{}) { g.History.Begin() })
+		s.Subscribe(gui.OnMouseUp, func(name string, ev interface{}) { g.History.End() })
+		s.Subscribe(gui.OnMouseUpOut, func(name string, ev interface{}) { g.History.End() })
+	}
 
 	debugBtn := gui.NewCheckBox("dbg")
 	debugBtn.SetPosition(10, float32(150))
@@ -120,38 +155,121 @@ func placeButtons(scene *core.Node, labels []string, g *GuiState, v volume.Volum
 	debugBtn.SetVisible(true)
 	debugBtn.SetBordersColor(math32.NewColor("black"))
 	debugBtn.Subscribe(gui.OnClick, func(name string, ev interface{}) {
-		g.Debug = !g.Debug
-		g.Dirty = true
+		g.toggleDebug()
 	})
 	scene.Add(debugBtn)
+	g.DebugBox = debugBtn
+
+	// The 3D view shows the planes, the volume rendered, or a projection.
+	modes := gui.NewDropDown(120, gui.NewImageLabel("Planes"))
+	modes.SetPosition(10, 300)
+	projections := []volume.Projection{volume.NoProjection, volume.NoProjection, volume.MaxIntensity, volume.MinIntensity, volume.MeanIntensity}
+	for _, name := range []string{"Planes", "Volume rendering", "MIP", "MinIP", "Average"} {
+		modes.Add(gui.NewImageLabel(name))
+	}
+	modes.Subscribe(gui.OnChange, func(name string, ev interface{}) {
+		pos := modes.SelectedPos()
+		g.DVR.Enabled = pos > 0
+		g.DVR.Projection = projections[pos]
+		g.DVR.Invalidate()
+	})
+	scene.Add(modes)
+	transferBtn := gui.NewButton("Transfer function")
+	transferBtn.SetPosition(140, 296)
+	transferBtn.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		g.Transfer.SetVisible(!g.Transfer.Visible())
+	})
+	scene.Add(transferBtn)
 }
 
-func Init(v volume.Volume) {
+// renderOverview draws the 3D view into the current viewport: the slice
+// planes in the scene, the volume rendering with the volume's outline, or
+// the projection alone.
+func (g *GuiState) renderOverview(gs *gls.GLS, r *renderer.Renderer, scene *core.Node, cam *camera.Camera, debug bool) {
+	if g.DVR.Enabled {
+		g.DVR.Render(r, cam.Aspect())
+		if g.DVR.Projection == volume.NoProjection {
+			// Only the rendering shares the camera's perspective.
+			gs.Clear(gls.DEPTH_BUFFER_BIT)
+			_ = r.Render(scene, cam)
+			_ = r.Render(g.DVR.box, cam)
+		}
+		return
+	}
+	_ = r.Render(scene, cam)
+	for _, n := range []*core.Node{g.AxialNode, g.CoronalNode, g.SagittallNode, g.CustomNode} {
+		if n != nil {
+			_ = r.Render(n, cam)
+		}
+	}
+	if debug {
+		_ = r.Render(g.DebugNode, cam)
+	}
+}
+
+// Init opens the viewer on v. Measurements are saved to and loaded from the
+// structured report at opts.SRPath, loaded at start when it exists.
+func Init(v volume.Volume, opts Options) {
 	a := app.App()
+	root := core.NewNode()
 	scene := core.NewNode()
+	layout := NewLayout()
 	guiState := GuiState{
 		Debug:       true,
 		Dirty:       true,
 		Slice:       math32.NewVector3(float32(v.DcmData.Cols)/2, float32(v.DcmData.Rows)/2, float32(v.DcmData.Depth)/2),
 		AxialNode:   core.NewNode(),
 		CoronalNode: core.NewNode(),
+		Oblique:     math32.NewMatrix4(),
 		DebugNode:   core.NewNode(),
 		Axial:       volume.SliceFrame{},
 		Coronal:     volume.SliceFrame{},
 		Sagittal:    volume.SliceFrame{},
+		DVR:         newDvrView(v),
+		Clip:        newClipState(v),
+	}
+	guiState.Surface = newSurfaceView(&v)
+	guiState.Fusion = opts.Fusion
+
+	// The gui manager watches the overlay, drawn over the whole window
+	gui.Manager().Set(root)
+	guiState.AxialView = NewViewport2D("Axial", 2, root, math32.NewColor("blue"))
+	guiState.CoronalView = NewViewport2D("Coronal", 1, root, math32.NewColor("green"))
+	guiState.SagittalView = NewViewport2D("Sagittal", 0, root, math32.NewColor("red"))
+	for _, vp := range guiState.Views() {
+		vp.VoxelSize = math32.Min(v.DcmData.VoxelSize.X, v.DcmData.VoxelSize.Y)
+		vp.Overlay = guiState.fuse
 	}
 
+	tf := volume.WindowTransfer(v.DcmData.Window, v.DcmData.Level)
+	if loaded, err := volume.LoadTransferFunction(opts.TransferPath); err == nil {
+		tf = loaded
+		guiState.DVR.SetTransfer(&tf)
+	} else if !os.IsNotExist(err) {
+		log.Println("transfer function:", err)
+	}
+	guiState.Transfer = newTransferEditor(v, tf, opts.TransferPath, guiState.DVR.SetTransfer)
+	guiState.Transfer.SetVisible(false)
+	root.Add(guiState.Transfer)
+
 	var btns []string
 	btns = append(btns, "X", "Y", "Z", "Reset")
-	// Set the scene to be managed by the gui manager
-	gui.Manager().Set(scene)
-	placeButtons(scene, btns, &guiState, v)
+	controls := gui.NewPanel(420, 420)
+	root.Add(controls)
+	placeButtons(controls, btns, &guiState, v)
+	placeLUTMenu(controls, &guiState, &v, opts.LUT)
+	placeMeasureButtons(controls, &guiState)
+	placeReportButtons(controls, &guiState, v, opts.SRPath)
+	if _, err := os.Stat(opts.SRPath); err == nil {
+		guiState.loadReport(opts.SRPath)
+	}
 
 	// Create camera and orbit control
 	width, height := a.GetSize()
 	aspect := float32(width) / float32(height)
 	cam := camera.New(aspect)
-	cam.SetPosition(60, 100, 300)
+	center := v.GetCorners().Box.Center(nil)
+	cam.SetPositionVec(math32.NewVector3(60, 100, 300).Add(center))
 	scene.Add(cam)
 	// Create and add lights to the scene
 	scene.Add(light.NewAmbient(&math32.Color{1.0, 1.0, 1.0}, 0.8))
@@ -162,44 +280,223 @@ func Init(v volume.Volume) {
 	// Create and add an axis helper to the scene
 	axis := helper.NewAxes(1000)
 	scene.Add(axis)
+	scene.Add(guiState.Surface.node)
+	scene.Add(guiState.Clip.node)
 
 	// Set up orbit control for the camera
-	camera.NewOrbitControl(cam)
+	orbit := camera.NewOrbitControl(cam)
+	orbit.SetTarget(*center)
 
-	// Set up callback to update viewport and camera aspect ratio when the window is resized
+	var fusion *gui.Panel
+	if guiState.Fusion != nil {
+		fusion = newFusionPanel(&guiState, &v)
+		root.Add(fusion)
+	}
+
+	// Set up callback to lay out the panes and update the cameras when the window is resized
+	var overview Pane
 	onResize := func(evname string, ev interface{}) {
-		// Get framebuffer size and update viewport accordingly
 		width, height := a.GetSize()
-		a.Gls().Viewport(0, 0, int32(width), int32(height))
+		layout.Resize(width, height)
+		var axial, coronal, sagittal Pane
+		axial, coronal, sagittal, overview = layout.Panes()
+		guiState.AxialView.SetPane(axial)
+		guiState.CoronalView.SetPane(coronal)
+		guiState.SagittalView.SetPane(sagittal)
+		controls.SetPosition(float32(overview.X), float32(overview.Y))
+		guiState.Transfer.SetPosition(float32(overview.X)+10, float32(overview.Y)+controls.Height())
+		if fusion != nil {
+			fusion.SetPosition(float32(overview.X+overview.Width)-fusion.Width()-10, float32(overview.Y)+10)
+		}
 		// Update the camera's aspect ratio
-		cam.SetAspect(float32(width) / float32(height))
+		cam.SetAspect(overview.Aspect())
 	}
 
 	a.Subscribe(window.OnWindowSize, onResize)
 	onResize("", nil)
 
-	a.Gls().ClearColor(1, 1, 1, 1.0)
+	// The value modes start from the values as loaded, before a session
+	// changes them.
+	placeValueMenu(controls, &guiState, &v, opts.Values)
+	restore := func(s Session) {
+		guiState.restore(s, &v, layout)
+		onResize("", nil)
+	}
+	placeSessionButtons(controls, func() Session {
+		return guiState.capture(v, layout, opts.Input)
+	}, restore, opts.SessionPath)
+	if opts.Session != nil {
+		restore(*opts.Session)
+	}
+	captureBtn := placeCaptureButton(controls, &guiState, opts.Capture)
+	placeCineButton(controls, captureBtn.Position().X+captureBtn.Width()+4, &v, opts.Cine)
+	placeSurfaceRow(controls, guiState.Surface, &v, opts.Surface)
+	placeClipRows(controls, &guiState, &v)
+
+	orientation := gui.NewLabel("")
+	orientation.SetPosition(10, 180)
+	controls.Add(orientation)
+	readout := gui.NewLabel("")
+	readout.SetPosition(10, 240)
+	controls.Add(readout)
+	var cursor math32.Vector2
+	gui.Manager().Subscribe(window.OnMouseDown, func(evname string, ev interface{}) {
+		mev := ev.(*window.MouseEvent)
+		if layout.Grab(mev.Xpos, mev.Ypos) {
+			return
+		}
+		if mev.Button == window.MouseButtonLeft && guiState.Clip.Grab(v, cam, overview, mev.Xpos, mev.Ypos) {
+			return
+		}
+		if mev.Button == window.MouseButtonRight {
+			guiState.panning = guiState.viewAt(mev.Xpos, mev.Ypos)
+			return
+		}
+		if mev.Button != window.MouseButtonLeft {
+			return
+		}
+		if vp := guiState.viewAt(mev.Xpos, mev.Ypos); vp != nil && guiState.Landmarks.Active {
+			guiState.Landmarks.Place(*vp.PatientAt(mev.Xpos, mev.Ypos))
+			guiState.Dirty = true
+		} else if vp != nil && guiState.Measuring.Active {
+			guiState.measureDown(vp, vp.PatientAt(mev.Xpos, mev.Ypos))
+		} else if vp != nil {
+			guiState.navigating = vp
+			guiState.History.Begin()
+			guiState.moveTo(v, vp.PatientAt(mev.Xpos, mev.Ypos), vp.Axis)
+		}
+	})
+	gui.Manager().Subscribe(window.OnMouseUp, func(evname string, ev interface{}) {
+		layout.Release()
+		guiState.Clip.Release()
+		guiState.navigating = nil
+		guiState.History.End()
+		guiState.panning = nil
+		guiState.measureUp()
+	})
+	if opts.Keys.Bindings == nil {
+		opts.Keys = DefaultKeyConfig()
+	}
+	actions, err := opts.Keys.Actions()
+	if err != nil {
+		log.Println("key bindings:", err)
+		actions, _ = DefaultKeyConfig().Actions()
+		opts.Keys = DefaultKeyConfig()
+	}
+	onKey := func(evname string, ev interface{}) {
+		kev := ev.(*window.KeyEvent)
+		vp := guiState.viewAt(cursor.X, cursor.Y)
+		if vp == nil {
+			vp = guiState.AxialView
+		}
+		action := actions[KeyBinding{kev.Key, kev.Mods}]
+		if action == actionCapture || action == actionCaptureClean {
+			req := opts.Capture
+			req.Overlays = action == actionCapture
+			guiState.pendingCapture = &req
+			return
+		}
+		guiState.perform(action, &v, opts.Keys.Presets, vp)
+	}
+	gui.Manager().Subscribe(window.OnKeyDown, onKey)
+	gui.Manager().Subscribe(window.OnKeyRepeat, onKey)
+	gui.Manager().Subscribe(window.OnScroll, func(evname string, ev interface{}) {
+		sev := ev.(*window.ScrollEvent)
+		vp := guiState.viewAt(cursor.X, cursor.Y)
+		if vp == nil || sev.Yoffset == 0 {
+			return
+		}
+		if sev.Mods&window.ModControl != 0 {
+			vp.ZoomAt(cursor.X, cursor.Y, math32.Pow(1.2, sev.Yoffset))
+			return
+		}
+		if sev.Yoffset > 0 {
+			guiState.step(v, vp.Axis, 1)
+		} else {
+			guiState.step(v, vp.Axis, -1)
+		}
+	})
+	gui.Manager().Subscribe(window.OnCursor, func(evname string, ev interface{}) {
+		cev := ev.(*window.CursorEvent)
+		if vp := guiState.panning; vp != nil {
+			vp.Pan(cev.Xpos-cursor.X, cev.Ypos-cursor.Y)
+		}
+		cursor.Set(cev.Xpos, cev.Ypos)
+		if layout.Drag(cev.Xpos, cev.Ypos) {
+			onResize("", nil)
+			return
+		}
+		if vp := guiState.navigating; vp != nil {
+			guiState.moveTo(v, vp.PatientAt(cev.Xpos, cev.Ypos), vp.Axis)
+		}
+		if vp := guiState.Measuring.view; vp != nil {
+			guiState.measureMove(vp.PatientAt(cev.Xpos, cev.Ypos))
+		}
+		guiState.Clip.Drag(v, cam, overview, cev.Xpos, cev.Ypos)
+		// The orbit keeps off the crop handles, so grabbing one moves it
+		// rather than the camera.
+		if overview.Contains(cev.Xpos, cev.Ypos) && !layout.Near(cev.Xpos, cev.Ypos) &&
+			!guiState.Clip.Dragging() && guiState.Clip.handleAt(v, cam, overview, cev.Xpos, cev.Ypos) < 0 {
+			orbit.SetEnabled(camera.OrbitAll)
+		} else {
+			orbit.SetEnabled(camera.OrbitNone)
+		}
+		readout.SetText("")
+		for _, vp := range guiState.Views() {
+			if vp.Pane.Contains(cev.Xpos, cev.Ypos) {
+				p := vp.PatientAt(cev.Xpos, cev.Ypos)
+				readout.SetText(readoutText(v, p) + guiState.fusionText(p))
+			}
+		}
+		if overview.Contains(cev.Xpos, cev.Ypos) {
+			sx, sy := overview.NDC(cev.Xpos, cev.Ypos)
+			if p, ok := pick(cam, &guiState, v, sx, sy); ok {
+				readout.SetText(readoutText(v, p) + guiState.fusionText(p))
+			}
+		}
+	})
 
+	// What the viewer opens with is not undone.
+	guiState.History = History{}
 	a.Run(func(renderer *renderer.Renderer, deltaTime time.Duration) {
 
+		_, height := a.GetSize()
 		a.Gls().Clear(gls.DEPTH_BUFFER_BIT | gls.STENCIL_BUFFER_BIT | gls.COLOR_BUFFER_BIT)
 
 		if guiState.Dirty {
-			updateAxial(&guiState, v)
-			updateCoronal(&guiState, v)
-			updateSagittal(&guiState, v)
-			guiState.AxialNode = Draw(guiState.Axial, v, guiState.AxialNode, math32.NewColor("blue"))
-			guiState.CoronalNode = Draw(guiState.Coronal, v, guiState.CoronalNode, math32.NewColor("green"))
-			guiState.SagittallNode = Draw(guiState.Sagittal, v, guiState.SagittallNode, math32.NewColor("red"))
-			guiState.DebugNode = DrawDebug(guiState.Sagittal, guiState.DebugNode, guiState.Debug)
+			guiState.refresh(v)
+			orientation.SetText(orientationText(&guiState))
 			guiState.Dirty = false
 		}
+		if req := guiState.pendingCapture; req != nil {
+			guiState.pendingCapture = nil
+			written, err := guiState.captureViews(a.Gls(), renderer, *req, cam, scene, axis)
+			for _, path := range written {
+				log.Println("captured", path)
+			}
+			if err != nil {
+				log.Println("capture:", err)
+			}
+		}
+
+		for _, vp := range guiState.Views() {
+			vp.UpdateDetail(v)
+			clearPane(a.Gls(), vp.Pane, height, &math32.Color{0, 0, 0})
+			vp.Render(a.Gls(), renderer, height)
+		}
+
+		guiState.Surface.Update()
+		guiState.Clip.Update(&guiState, v)
+		guiState.updateFusion(v)
+		clearPane(a.Gls(), overview, height, &math32.Color{1, 1, 1})
+		a.Gls().Viewport(overview.Viewport(height))
+		if guiState.DVR.Enabled {
+			guiState.DVR.Update(v, cam, overview)
+		}
+		guiState.renderOverview(a.Gls(), renderer, scene, cam, true)
 
-		renderer.Render(scene, cam)
-		renderer.Render(guiState.AxialNode, cam)
-		renderer.Render(guiState.CoronalNode, cam)
-		renderer.Render(guiState.SagittallNode, cam)
-		renderer.Render(guiState.DebugNode, cam)
+		a.Gls().Viewport(0, 0, int32(layout.Width), int32(height))
+		renderer.Render(root, cam)
 	})
 }
 
@@ -225,6 +522,7 @@ func Draw(sliceFrame volume.SliceFrame, v volume.Volume, node *core.Node, c *mat
 	addbox(v, scene, &math32.Color{1, 1, 1})
 	addDots(sliceFrame.AABB.CalibratedCorners, scene, c, false)
 	addDots(sliceFrame.Intersections, scene, c, false)
+	addOutline(sliceFrame.Intersections, scene, c)
 
 	addPlane(sliceFrame, v, scene)
 	addBasis(sliceFrame, v, scene)
@@ -294,6 +592,20 @@ func addDots(v []math32.Vector3, scene *core.Node, c *math32.Color, magnify bool
 	}
 }
 
+func addOutline(polygon []math32.Vector3, scene *core.Node, c *math32.Color) {
+	if len(polygon) < 2 {
+		return
+	}
+	geom := geometry.NewGeometry()
+	positions := math32.NewArrayF32(0, 0)
+	for _, p := range append(polygon, polygon[0]) {
+		positions.Append(p.X, p.Y, p.Z)
+	}
+	geom.AddVBO(gls.NewVBO(positions).AddAttrib(gls.VertexPosition))
+	mat := material.NewStandard(c)
+	scene.Add(graphic.NewLineStrip(geom, mat))
+}
+
 func addPlane(s volume.SliceFrame, v volume.Volume, scene *core.Node) {
 	w := s.ImageSizeInMm.X
 	h := s.ImageSizeInMm.Y
@@ -301,18 +613,27 @@ func addPlane(s volume.SliceFrame, v volume.Volume, scene *core.Node) {
 	plane.ApplyMatrix(math32.NewMatrix4().MakeTranslation(w/2, h/2, .5))
 
 	tex2 := texture.NewTexture2DFromRGBA(*s.Mpr)
+	// Row 0 of the reformat lies on the plane's local y = 0
+	tex2.SetFlipY(false)
 
 	mat1 := material.NewStandard(&math32.Color{1, 1, 1})
 	mat1.AddTexture(tex2)
 	mat1.SetSide(material.SideDouble)
 	mPlane := graphic.NewMesh(plane, mat1)
-	mPlane.SetMatrix(math32.NewMatrix4().Multiply(s.RotatedFrame.Basis).SetPosition(s.RotatedFrame.Origin))
+	mPlane.SetMatrix(math32.NewMatrix4().Multiply(s.RotatedFrame.Basis).SetPosition(s.ImageOrigin()))
 	scene.Add(mPlane)
 }
 
 func addbox(v volume.Volume, scene *core.Node, color *math32.Color) {
-	geom := geometry.NewBox(float32(v.DcmData.Cols), float32(v.DcmData.Rows), float32(v.DcmData.Depth))
-	geom.ApplyMatrix(math32.NewMatrix4().Identity().SetPosition(math32.NewVector3(float32(v.DcmData.Cols)/2, float32(v.DcmData.Rows)/2, float32(v.DcmData.Depth)/2)))
+	size := math32.NewVector3(float32(v.DcmData.Cols), float32(v.DcmData.Rows), float32(v.DcmData.Depth))
+	addVoxelBox(v, math32.NewBox3(math32.NewVec3(), size), scene, color)
+}
+
+// addVoxelBox adds the wireframe of box, in v's voxel coordinates.
+func addVoxelBox(v volume.Volume, box *math32.Box3, scene *core.Node, color *math32.Color) {
+	size := box.Size(nil)
+	geom := geometry.NewBox(size.X, size.Y, size.Z)
+	geom.ApplyMatrix(math32.NewMatrix4().Identity().SetPosition(box.Center(nil)))
 	mat := material.NewStandard(color)
 	mat.SetWireframe(true)
 	mesh := graphic.NewMesh(geom, mat)
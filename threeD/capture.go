@@ -0,0 +1,97 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/g3n/engine/camera"
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/renderer"
+)
+
+// CaptureRequest asks for the 3D view and every 2D viewport to be written to
+// Dir as PNGs of Width x Height pixels. Overlays are the crosshairs and
+// measurement outlines in the 2D views and the axes and debug helpers in 3D;
+// text labels belong to the GUI and are never captured.
+type CaptureRequest struct {
+	Dir      string
+	Width    int
+	Height   int
+	Overlays bool
+}
+
+// placeCaptureButton adds a button capturing the views as set by req.
+func placeCaptureButton(scene *gui.Panel, g *GuiState, req CaptureRequest) *gui.Button {
+	b := gui.NewButton("Capture")
+	b.SetPosition(250, 270)
+	b.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		r := req
+		g.pendingCapture = &r
+	})
+	scene.Add(b)
+	return b
+}
+
+// captureViews carries out req, drawing the 3D overview through cam with the
+// scene and the nodes of the planes, and returns the files written.
+func (g *GuiState) captureViews(gs *gls.GLS, r *renderer.Renderer, req CaptureRequest, cam *camera.Camera, scene *core.Node, helpers ...core.INode) ([]string, error) {
+	if req.Width <= 0 || req.Height <= 0 {
+		return nil, fmt.Errorf("capture size %dx%d", req.Width, req.Height)
+	}
+	if err := os.MkdirAll(req.Dir, 0755); err != nil {
+		return nil, err
+	}
+	o, err := newOffscreen(req.Width, req.Height)
+	if err != nil {
+		return nil, err
+	}
+	defer o.dispose()
+	stamp := time.Now().Format("20060102-150405")
+	aspect := float32(req.Width) / float32(req.Height)
+	var written []string
+	save := func(name string, img image.Image) error {
+		path := filepath.Join(req.Dir, fmt.Sprintf("capture-%s-%s.png", stamp, name))
+		if err := volume.WritePNG(path, img); err != nil {
+			return err
+		}
+		written = append(written, path)
+		return nil
+	}
+
+	for _, n := range helpers {
+		n.GetNode().SetVisible(req.Overlays)
+	}
+	saved := cam.Aspect()
+	cam.SetAspect(aspect)
+	img := o.render(gs, &math32.Color{1, 1, 1}, func() {
+		g.renderOverview(gs, r, scene, cam, req.Overlays)
+	})
+	cam.SetAspect(saved)
+	for _, n := range helpers {
+		n.GetNode().SetVisible(true)
+	}
+	if err := save("3d", img); err != nil {
+		return written, err
+	}
+
+	for _, vp := range g.Views() {
+		vp.cross.SetVisible(req.Overlays)
+		vp.marks.SetVisible(req.Overlays)
+		vp.Cam.SetAspect(aspect)
+		img := o.render(gs, &math32.Color{0, 0, 0}, func() { _ = r.Render(vp.Scene, vp.Cam) })
+		vp.Cam.SetAspect(vp.Pane.Aspect())
+		vp.cross.SetVisible(true)
+		vp.marks.SetVisible(true)
+		if err := save(vp.Name, img); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,50 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/g3n/engine/gui"
+)
+
+// CineRequest is the sweep the Cine button exports, as a GIF in Dir playing
+// at FPS frames a second. Without a window of its own the cine takes the
+// viewer's.
+type CineRequest struct {
+	Cine volume.Cine
+	Dir  string
+	FPS  float32
+}
+
+// placeCineButton adds a button exporting req from v at x on the capture row.
+// The frames are cut in the background from a snapshot of the volume, so the
+// viewer stays responsive meanwhile.
+func placeCineButton(scene *gui.Panel, x float32, v *volume.Volume, req CineRequest) {
+	b := gui.NewButton("Cine")
+	b.SetPosition(x, 270)
+	b.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		cine := req.Cine
+		if cine.WindowWidth <= 0 {
+			cine.WindowCenter, cine.WindowWidth = v.DcmData.Window, v.DcmData.Level
+		}
+		snapshot := *v
+		// The GIF's palette is gray.
+		snapshot.DcmData.LUT = nil
+		path := filepath.Join(req.Dir, fmt.Sprintf("cine-%s-%s.gif", time.Now().Format("20060102-150405"), cine.Mode))
+		go func() {
+			frames, err := cine.Frames(snapshot)
+			if err == nil {
+				_, err = volume.WriteCine(path, frames, req.FPS)
+			}
+			if err != nil {
+				log.Println("cine:", err)
+				return
+			}
+			log.Printf("cine of %d frames written to %s", len(frames), path)
+		}()
+	})
+	scene.Add(b)
+}
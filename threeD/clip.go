@@ -0,0 +1,244 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+
+	"github.com/g3n/engine/camera"
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+)
+
+// handleGrab is how close, in pixels, the cursor must be to a crop handle to
+// drag it.
+const handleGrab = 10
+
+// Clip plane ties: off, or keeping the side of the plane its normal points
+// to, or the other.
+const (
+	tieOff = iota
+	tieFront
+	tieBack
+)
+
+var cropColor = math32.NewColor("orange")
+
+// clipState is the crop box, dragged by the handles at the middle of its
+// faces in the 3D view, and the clipping planes tied to the MPR planes. It
+// applies to the volume rendering and the surface.
+type clipState struct {
+	Crop bool
+	// Box is the crop box in voxel coordinates, between voxel centres.
+	Box math32.Box3
+	// Ties are the clipping planes on the axial, coronal, sagittal and
+	// oblique planes.
+	Ties [4]int
+
+	node    *core.Node
+	drawn   math32.Box3
+	grabbed int
+	from    math32.Vector2
+	start   float32
+	applied volume.Clip
+}
+
+func newClipState(v volume.Volume) *clipState {
+	c := &clipState{node: core.NewNode(), grabbed: -1}
+	c.Reset(v)
+	return c
+}
+
+// Reset crops to the whole volume.
+func (c *clipState) Reset(v volume.Volume) {
+	size := dims(v)
+	c.Box = math32.Box3{Max: math32.Vector3{X: float32(size[0] - 1), Y: float32(size[1] - 1), Z: float32(size[2] - 1)}}
+}
+
+// Clip is what the crop box and the ties leave of the volume as g stands.
+func (c *clipState) Clip(g *GuiState) volume.Clip {
+	var clip volume.Clip
+	if c.Crop {
+		box := c.Box
+		clip.Box = &box
+	}
+	for i, frame := range []volume.SliceFrame{g.Axial, g.Coronal, g.Sagittal, g.Custom} {
+		if c.Ties[i] == tieOff || frame.RotatedFrame.Plane == nil {
+			continue
+		}
+		plane := *frame.RotatedFrame.Plane
+		if c.Ties[i] == tieBack {
+			plane.Negate()
+		}
+		clip.Planes = append(clip.Planes, plane)
+	}
+	return clip
+}
+
+// Update hands the clip to the volume rendering and the surface when it
+// changed, and redraws the crop box.
+func (c *clipState) Update(g *GuiState, v volume.Volume) {
+	clip := c.Clip(g)
+	if !sameClip(clip, c.applied) {
+		c.applied = clip
+		g.DVR.SetClip(clip)
+		g.Surface.SetClip(clip)
+	}
+	c.node.SetVisible(c.Crop)
+	if c.Crop && c.Box != c.drawn {
+		c.drawn = c.Box
+		c.node.RemoveAll(true)
+		addVoxelBox(v, &c.Box, c.node, cropColor)
+		radius := float64(v.GetCorners().Box.Size(nil).Length() / 100)
+		for i, p := range c.handles(v) {
+			mat := material.NewStandard(cropColor)
+			if i == c.grabbed {
+				mat = material.NewStandard(&math32.Color{1, 1, 0})
+			}
+			handle := graphic.NewMesh(geometry.NewSphere(radius, 8, 8), mat)
+			handle.SetPositionVec(&p)
+			c.node.Add(handle)
+		}
+	}
+}
+
+func sameClip(a volume.Clip, b volume.Clip) bool {
+	if (a.Box == nil) != (b.Box == nil) || (a.Box != nil && *a.Box != *b.Box) || len(a.Planes) != len(b.Planes) {
+		return false
+	}
+	for i := range a.Planes {
+		if a.Planes[i] != b.Planes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// handles returns the middle of the crop box's faces in patient
+// coordinates: low x, high x, low y and so on.
+func (c *clipState) handles(v volume.Volume) [6]math32.Vector3 {
+	var hs [6]math32.Vector3
+	center := c.Box.Center(nil)
+	for i := range hs {
+		h := *center
+		if i%2 == 0 {
+			h.SetComponent(i/2, c.Box.Min.Component(i/2))
+		} else {
+			h.SetComponent(i/2, c.Box.Max.Component(i/2))
+		}
+		hs[i] = *h.ApplyMatrix4(v.DcmData.Calibration)
+	}
+	return hs
+}
+
+// handleAt returns the handle under window point x, y in pane, seen through
+// cam, or -1.
+func (c *clipState) handleAt(v volume.Volume, cam *camera.Camera, pane Pane, x float32, y float32) int {
+	if !c.Crop || !pane.Contains(x, y) {
+		return -1
+	}
+	best, bestDist := -1, float32(handleGrab)
+	for i, h := range c.handles(v) {
+		hx, hy := onScreen(cam, pane, h)
+		if d := math32.Sqrt((hx-x)*(hx-x) + (hy-y)*(hy-y)); d <= bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// onScreen returns the window point where patient point p shows in pane.
+func onScreen(cam *camera.Camera, pane Pane, p math32.Vector3) (float32, float32) {
+	cam.Project(&p)
+	return pane.Window(p.X, p.Y)
+}
+
+// Grab starts dragging the handle under x, y, reporting whether there is one.
+func (c *clipState) Grab(v volume.Volume, cam *camera.Camera, pane Pane, x float32, y float32) bool {
+	c.grabbed = c.handleAt(v, cam, pane, x, y)
+	if c.grabbed < 0 {
+		return false
+	}
+	c.from = math32.Vector2{X: x, Y: y}
+	c.start = c.face(c.grabbed)
+	c.drawn = math32.Box3{}
+	return true
+}
+
+func (c *clipState) Release() {
+	if c.grabbed >= 0 {
+		c.grabbed = -1
+		c.drawn = math32.Box3{}
+	}
+}
+
+func (c *clipState) Dragging() bool {
+	return c.grabbed >= 0
+}
+
+func (c *clipState) face(i int) float32 {
+	if i%2 == 0 {
+		return c.Box.Min.Component(i / 2)
+	}
+	return c.Box.Max.Component(i / 2)
+}
+
+// Drag moves the grabbed face along its axis as far as the cursor, now at
+// x, y, has moved along the axis on screen.
+func (c *clipState) Drag(v volume.Volume, cam *camera.Camera, pane Pane, x float32, y float32) {
+	if c.grabbed < 0 {
+		return
+	}
+	axis := c.grabbed / 2
+	h := c.handles(v)[c.grabbed]
+	step := math32.NewVec3()
+	step.SetComponent(axis, 1)
+	step.ApplyMatrix4(v.DcmData.Calibration).Sub(math32.NewVec3().ApplyMatrix4(v.DcmData.Calibration)).Add(&h)
+	hx, hy := onScreen(cam, pane, h)
+	sx, sy := onScreen(cam, pane, *step)
+	// The cursor's movement along one voxel's step on screen.
+	dx, dy := sx-hx, sy-hy
+	length := dx*dx + dy*dy
+	if length < 1e-6 {
+		return
+	}
+	voxels := ((x-c.from.X)*dx + (y-c.from.Y)*dy) / length
+	value := c.start + voxels
+	if c.grabbed%2 == 0 {
+		c.Box.Min.SetComponent(axis, math32.Clamp(value, 0, c.Box.Max.Component(axis)-1))
+	} else {
+		c.Box.Max.SetComponent(axis, math32.Clamp(value, c.Box.Min.Component(axis)+1, float32(dims(v)[axis]-1)))
+	}
+}
+
+// placeClipRows adds the crop box switch and reset, and a menu per MPR plane
+// tying a clipping plane to it.
+func placeClipRows(scene *gui.Panel, g *GuiState, v *volume.Volume) {
+	crop := gui.NewCheckBox("Crop box")
+	crop.SetPosition(10, 364)
+	crop.Subscribe(gui.OnChange, func(name string, ev interface{}) {
+		g.Clip.Crop = crop.Value()
+	})
+	scene.Add(crop)
+	reset := gui.NewButton("Reset crop")
+	reset.SetPosition(100, 360)
+	reset.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		g.Clip.Reset(*v)
+	})
+	scene.Add(reset)
+
+	for i, name := range []string{"Axial", "Coronal", "Sagittal", "Oblique"} {
+		i := i
+		ties := gui.NewDropDown(98, gui.NewImageLabel(name+": off"))
+		ties.SetPosition(10+float32(i)*102, 392)
+		for _, side := range []string{"off", "front", "back"} {
+			ties.Add(gui.NewImageLabel(name + ": " + side))
+		}
+		ties.Subscribe(gui.OnChange, func(name string, ev interface{}) {
+			g.Clip.Ties[i] = ties.SelectedPos()
+		})
+		scene.Add(ties)
+	}
+}
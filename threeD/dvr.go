@@ -0,0 +1,181 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"image"
+
+	"github.com/g3n/engine/camera"
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/light"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/renderer"
+)
+
+// ViewUnprojection returns the inverse view-projection matrix of cam, taking
+// normalized device coordinates back to the world.
+func ViewUnprojection(cam *camera.Camera) math32.Matrix4 {
+	var view, proj, inverse math32.Matrix4
+	cam.ViewMatrix(&view)
+	cam.ProjMatrix(&proj)
+	inverse.GetInverse(proj.Multiply(&view))
+	return inverse
+}
+
+const (
+	previewRows = 128
+	maxDvrRows  = 384
+)
+
+// dvrView shows a VolumeRenderer's image behind the 3D overview, or with a
+// Projection, the volume projected along the camera's line of sight.
+// Rendering runs in the background: a quick preview while the camera moves,
+// then a sharper image once it stops.
+type dvrView struct {
+	Enabled    bool
+	Renderer   *volume.VolumeRenderer
+	Projection volume.Projection
+	// Auto makes the transfer function follow the slices' window.
+	Auto bool
+
+	scene   *core.Node
+	cam     *camera.Camera
+	image   *graphic.Mesh
+	box     *core.Node
+	results chan dvrResult
+	busy    bool
+	shown   math32.Matrix4
+	full    bool
+	window  [2]float32
+	dirty   bool
+}
+
+type dvrResult struct {
+	img    *image.RGBA
+	view   math32.Matrix4
+	full   bool
+	aspect float32
+}
+
+func newDvrView(v volume.Volume) *dvrView {
+	d := &dvrView{
+		Renderer: volume.NewVolumeRenderer(volume.WindowTransfer(v.DcmData.Window, v.DcmData.Level)),
+		Auto:     true,
+		scene:    core.NewNode(),
+		cam:      camera.NewOrthographic(1, 0.1, 100, 2, camera.Vertical),
+		box:      core.NewNode(),
+		results:  make(chan dvrResult, 1),
+		window:   [2]float32{v.DcmData.Window, v.DcmData.Level},
+	}
+	d.cam.SetPosition(0, 0, 10)
+	d.scene.Add(d.cam)
+	d.scene.Add(light.NewAmbient(&math32.Color{1, 1, 1}, 1))
+	addbox(v, d.box, &math32.Color{1, 1, 0})
+	return d
+}
+
+// SetClip renders what clip leaves of the volume.
+func (d *dvrView) SetClip(clip volume.Clip) {
+	d.Renderer.Clip = clip
+	d.Invalidate()
+}
+
+// SetTransfer renders with tf, or with the slices' window when tf is nil.
+func (d *dvrView) SetTransfer(tf *volume.TransferFunction) {
+	d.Auto = tf == nil
+	if tf == nil {
+		// Force the window to be picked up again.
+		d.window = [2]float32{}
+	} else {
+		d.Renderer.Transfer = *tf
+	}
+	d.Invalidate()
+}
+
+// Invalidate re-renders the image, after a change of transfer function or
+// volume.
+func (d *dvrView) Invalidate() {
+	d.dirty = true
+}
+
+// Update shows any finished image and starts the next one the view needs.
+// Call it every frame with the overview's camera and pane.
+func (d *dvrView) Update(v volume.Volume, cam *camera.Camera, pane Pane) {
+	select {
+	case res := <-d.results:
+		d.busy = false
+		d.shown, d.full = res.view, res.full
+		if d.image != nil {
+			d.scene.Remove(d.image)
+			d.image.Dispose()
+		}
+		d.image = texturedPlane(res.img, 2*res.aspect, 2)
+		d.scene.Add(d.image)
+	default:
+	}
+	if d.busy || pane.Width <= 0 || pane.Height <= 0 {
+		return
+	}
+	if d.window != [2]float32{v.DcmData.Window, v.DcmData.Level} {
+		d.window = [2]float32{v.DcmData.Window, v.DcmData.Level}
+		if d.Auto {
+			d.Renderer.Transfer = volume.WindowTransfer(d.window[0], d.window[1])
+		}
+		d.dirty = true
+	}
+	view := ViewUnprojection(cam)
+	full := false
+	if view == d.shown && !d.dirty {
+		if d.full {
+			return
+		}
+		full = true
+	}
+	d.dirty = false
+	rows := previewRows
+	if full {
+		rows = pane.Height
+		if rows > maxDvrRows {
+			rows = maxDvrRows
+		}
+	}
+	d.busy = true
+	if d.Projection != volume.NoProjection {
+		frame := projectionFrame(v, cam, rows)
+		projection := d.Projection
+		go func() {
+			size := v.GetCorners().Box.Size(nil).Length()
+			frame.Project(v, projection, -size/2, size/2)
+			d.results <- dvrResult{*frame.Mpr, view, full, 1}
+		}()
+		return
+	}
+	aspect := pane.Aspect()
+	cols := int(float32(rows) * aspect)
+	renderer := *d.Renderer
+	go func() {
+		img := renderer.Render(v, view, cols, rows)
+		d.results <- dvrResult{img, view, full, aspect}
+	}()
+}
+
+// projectionFrame is the square frame through the volume centre facing cam,
+// size pixels across and wide enough for the volume from any side.
+func projectionFrame(v volume.Volume, cam *camera.Camera, size int) volume.SliceFrame {
+	world := cam.MatrixWorld()
+	rotation := math32.NewMatrix4().ExtractRotation(&world)
+	right := math32.NewVector3(1, 0, 0).ApplyMatrix4(rotation)
+	down := math32.NewVector3(0, -1, 0).ApplyMatrix4(rotation)
+	forward := math32.NewVector3(0, 0, -1).ApplyMatrix4(rotation)
+	basis := math32.NewMatrix4().MakeBasis(right, down, forward)
+	box := v.GetCorners().Box
+	half := box.Size(nil).Length() / 2
+	square := volume.Box2f{Min: math32.NewVector2(-half, -half), Max: math32.NewVector2(half, half)}
+	return volume.MakeSliceFrame(box.Center(nil), basis, v).Region(square, 2*half/float32(size))
+}
+
+// Render draws the image filling the current viewport.
+func (d *dvrView) Render(r *renderer.Renderer, aspect float32) {
+	d.cam.SetAspect(aspect)
+	_ = r.Render(d.scene, d.cam)
+}
@@ -0,0 +1,190 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"fmt"
+	"log"
+
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+)
+
+// Fusion is a secondary volume, PET over CT say, blended over the primary's
+// images with its own window and LUT, kept in its DcmData. Both are
+// resampled through patient coordinates, so they need to be registered.
+type Fusion struct {
+	Volume  volume.Volume
+	Opacity float32
+	Enabled bool
+	// Matrix is the registration already applied to Volume, taking its
+	// patient coordinates to the primary's, nil for none.
+	Matrix *math32.Matrix4
+	// Registration is how the panel's Register button aligns Volume onto the
+	// primary. The matrix is saved to RegistrationPath when set.
+	Registration     volume.Registration
+	RegistrationPath string
+
+	registered chan registered
+	busy       bool
+}
+
+// registered is the outcome of a registration run in the background.
+type registered struct {
+	matrix *math32.Matrix4
+	score  float32
+	err    error
+}
+
+// fuse blends the fusion volume, when on, over the reformat of frame.
+func (g *GuiState) fuse(frame volume.SliceFrame) {
+	if f := g.Fusion; f != nil && f.Enabled {
+		frame.Fuse(f.Volume, f.Opacity)
+	}
+}
+
+// fusionText reads the fusion volume at patient point p for the readout.
+func (g *GuiState) fusionText(p *math32.Vector3) string {
+	if g.Fusion == nil || !g.Fusion.Enabled {
+		return ""
+	}
+	index, inside := g.Fusion.Volume.VoxelIndex(p)
+	if !inside {
+		return ""
+	}
+	return "  fused " + g.Fusion.Volume.DcmData.FormatValue(g.Fusion.Volume.Value(index))
+}
+
+// updateFusion applies a finished registration. Call it every frame.
+func (g *GuiState) updateFusion(v volume.Volume) {
+	f := g.Fusion
+	if f == nil {
+		return
+	}
+	select {
+	case res := <-f.registered:
+		f.busy = false
+		if res.err != nil {
+			log.Println("registration:", res.err)
+			return
+		}
+		log.Printf("registered, %s %.4g", f.Registration.Metric, res.score)
+		g.applyRegistration(v, res.matrix, f.Registration.Kind)
+	default:
+	}
+}
+
+// applyRegistration moves the fusion volume and its landmarks by m, a
+// registration of the kind given onto the primary v, saves the whole matrix
+// applied and re-cuts v's images.
+func (g *GuiState) applyRegistration(v volume.Volume, m *math32.Matrix4, kind volume.TransformKind) {
+	f := g.Fusion
+	f.Volume = f.Volume.Transformed(m)
+	for i := range g.Landmarks.Moving {
+		g.Landmarks.Moving[i].ApplyMatrix4(m)
+	}
+	if f.Matrix != nil {
+		m = math32.NewMatrix4().MultiplyMatrices(m, f.Matrix)
+	}
+	f.Matrix = m
+	if f.RegistrationPath != "" {
+		if err := volume.WriteRegistration(f.RegistrationPath, v, f.Volume, f.Matrix, kind); err != nil {
+			log.Println("saving registration:", err)
+		} else {
+			log.Println("registration saved to", f.RegistrationPath)
+		}
+	}
+	g.recut(v)
+}
+
+const fusionWidth = 260
+
+// newFusionPanel builds the fusion switch, opacity, LUT and window of
+// g.Fusion, re-cutting v's images on every change.
+func newFusionPanel(g *GuiState, v *volume.Volume) *gui.Panel {
+	f := g.Fusion
+	panel := gui.NewPanel(fusionWidth, 260)
+	panel.SetColor4(&math32.Color4{0.85, 0.85, 0.85, 0.9})
+	changed := func() { g.recut(*v) }
+
+	on := gui.NewCheckBox("Fusion")
+	on.SetPosition(4, 6)
+	on.SetValue(f.Enabled)
+	on.Subscribe(gui.OnChange, func(name string, ev interface{}) {
+		f.Enabled = on.Value()
+		changed()
+	})
+	panel.Add(on)
+
+	luts := []*volume.LUT{nil}
+	menu := gui.NewDropDown(130, gui.NewImageLabel(volume.LUTNames[0]))
+	menu.SetPosition(fusionWidth-134, 4)
+	menu.Add(gui.NewImageLabel(volume.LUTNames[0]))
+	for _, name := range volume.LUTNames[1:] {
+		lut, err := volume.BuiltinLUT(name)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		luts = append(luts, lut)
+		menu.Add(gui.NewImageLabel(name))
+	}
+	menu.Subscribe(gui.OnChange, func(name string, ev interface{}) {
+		f.Volume.DcmData.LUT = luts[menu.SelectedPos()]
+		changed()
+	})
+	panel.Add(menu)
+	for i, lut := range luts {
+		if lut != nil && f.Volume.DcmData.LUT != nil && lut.Name == f.Volume.DcmData.LUT.Name {
+			menu.SelectPos(i)
+		}
+	}
+
+	// Sliders over the fusion volume's range of values.
+	_, min, max := f.Volume.Histogram(1)
+	span := math32.Max(max-min, 1)
+	// set takes the slider's position, from 0 to 1, and returns the value to
+	// show.
+	slider := func(y float32, label string, value float32, set func(float32) float32) {
+		s := gui.NewHSlider(fusionWidth-8, 28)
+		s.SetPosition(4, y)
+		s.SetValue(value)
+		s.SetText(fmt.Sprintf("%s %.4g", label, set(value)))
+		s.Subscribe(gui.OnChange, func(name string, ev interface{}) {
+			s.SetText(fmt.Sprintf("%s %.4g", label, set(s.Value())))
+			changed()
+		})
+		panel.Add(s)
+	}
+	data := &f.Volume.DcmData
+	slider(36, "opacity", f.Opacity, func(x float32) float32 {
+		f.Opacity = x
+		return x
+	})
+	slider(74, "center", (data.Window-min)/span, func(x float32) float32 {
+		data.Window = min + x*span
+		return data.Window
+	})
+	slider(112, "width", data.Level/span, func(x float32) float32 {
+		data.Level = math32.Max(x*span, 1)
+		return data.Level
+	})
+
+	// Registration runs on copies, as the viewer goes on meanwhile.
+	f.registered = make(chan registered, 1)
+	register := gui.NewButton("Register " + f.Registration.Kind.String())
+	register.SetPosition(4, 150)
+	register.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		if f.busy {
+			return
+		}
+		f.busy = true
+		fixed, moving := *v, f.Volume
+		go func() {
+			m, score, err := f.Registration.Register(fixed, moving)
+			f.registered <- registered{m, score, err}
+		}()
+	})
+	panel.Add(register)
+	placeLandmarkRows(panel, g, v, 180)
+	return panel
+}
@@ -0,0 +1,179 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+
+	"github.com/g3n/engine/math32"
+)
+
+const historyLimit = 100
+
+// Command is an undoable change to the viewer.
+type Command interface {
+	Apply(g *GuiState, v *volume.Volume)
+	Revert(g *GuiState, v *volume.Volume)
+}
+
+type sliceMove struct{ from, to math32.Vector3 }
+
+func (c sliceMove) Apply(g *GuiState, v *volume.Volume)  { g.setSlice(*v, &c.to) }
+func (c sliceMove) Revert(g *GuiState, v *volume.Volume) { g.setSlice(*v, &c.from) }
+
+type planeRotation struct{ from, to math32.Matrix4 }
+
+func (c planeRotation) Apply(g *GuiState, v *volume.Volume)  { g.setOblique(*v, &c.to) }
+func (c planeRotation) Revert(g *GuiState, v *volume.Volume) { g.setOblique(*v, &c.from) }
+
+type windowChange struct{ from, to [2]float32 }
+
+func (c windowChange) Apply(g *GuiState, v *volume.Volume)  { g.setWindow(v, c.to) }
+func (c windowChange) Revert(g *GuiState, v *volume.Volume) { g.setWindow(v, c.from) }
+
+type annotationEdit struct{ from, to []volume.Measurement }
+
+func (c annotationEdit) Apply(g *GuiState, v *volume.Volume)  { g.setMeasurements(c.to) }
+func (c annotationEdit) Revert(g *GuiState, v *volume.Volume) { g.setMeasurements(c.from) }
+
+// batch groups the commands of one change.
+type batch []Command
+
+func (c batch) Apply(g *GuiState, v *volume.Volume) {
+	for _, cmd := range c {
+		cmd.Apply(g, v)
+	}
+}
+
+func (c batch) Revert(g *GuiState, v *volume.Volume) {
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i].Revert(g, v)
+	}
+}
+
+// merge returns the command doing last and then next, when both change the
+// same thing.
+func merge(last Command, next Command) (Command, bool) {
+	switch l := last.(type) {
+	case sliceMove:
+		if n, ok := next.(sliceMove); ok {
+			return sliceMove{l.from, n.to}, true
+		}
+	case planeRotation:
+		if n, ok := next.(planeRotation); ok {
+			return planeRotation{l.from, n.to}, true
+		}
+	case windowChange:
+		if n, ok := next.(windowChange); ok {
+			return windowChange{l.from, n.to}, true
+		}
+	case annotationEdit:
+		if n, ok := next.(annotationEdit); ok {
+			return annotationEdit{l.from, n.to}, true
+		}
+	}
+	return nil, false
+}
+
+// History records viewer changes as commands for undo and redo. Each change
+// is recorded where it is made; those made during one drag, between Begin
+// and End, undo as one.
+type History struct {
+	done   []Command
+	undone []Command
+	// dragging is set between Begin and End, joined once the drag recorded
+	// a command.
+	dragging bool
+	joined   bool
+}
+
+// Record adds cmd, already applied to the viewer.
+func (h *History) Record(cmd Command) {
+	h.undone = nil
+	if h.joined {
+		if merged, ok := merge(h.done[len(h.done)-1], cmd); ok {
+			h.done[len(h.done)-1] = merged
+			return
+		}
+	}
+	h.done = append(h.done, cmd)
+	if len(h.done) > historyLimit {
+		h.done = h.done[1:]
+	}
+	h.joined = h.dragging
+}
+
+// Begin starts a drag.
+func (h *History) Begin() {
+	h.dragging, h.joined = true, false
+}
+
+// End finishes the drag, if any.
+func (h *History) End() {
+	h.dragging, h.joined = false, false
+}
+
+func (h *History) Undo(g *GuiState, v *volume.Volume) {
+	h.End()
+	if len(h.done) == 0 {
+		return
+	}
+	cmd := h.done[len(h.done)-1]
+	h.done = h.done[:len(h.done)-1]
+	cmd.Revert(g, v)
+	h.undone = append(h.undone, cmd)
+}
+
+func (h *History) Redo(g *GuiState, v *volume.Volume) {
+	h.End()
+	if len(h.undone) == 0 {
+		return
+	}
+	cmd := h.undone[len(h.undone)-1]
+	h.undone = h.undone[:len(h.undone)-1]
+	cmd.Apply(g, v)
+	h.done = append(h.done, cmd)
+}
+
+func (g *GuiState) setSlice(v volume.Volume, slice *math32.Vector3) {
+	g.Slice.Copy(slice)
+	g.syncSliders(v)
+	g.Dirty = true
+}
+
+func (g *GuiState) setOblique(v volume.Volume, basis *math32.Matrix4) {
+	g.Oblique.Copy(basis)
+	updateFree(g, v)
+	g.Dirty = true
+}
+
+func (g *GuiState) setWindow(v *volume.Volume, window [2]float32) {
+	v.SetWindow(window[0], window[1])
+	g.recut(*v)
+}
+
+// recut cuts every image again, once the way they are shown changed.
+func (g *GuiState) recut(v volume.Volume) {
+	g.cut = nil
+	if g.Custom.RotatedFrame.Plane != nil {
+		updateFree(g, v)
+	}
+	g.Dirty = true
+}
+
+// setLUT colours the images with lut, gray when nil.
+func (g *GuiState) setLUT(v *volume.Volume, lut *volume.LUT) {
+	v.DcmData.LUT = lut
+	g.recut(*v)
+}
+
+// recordSlice records the slice's move from from, if it moved.
+func (g *GuiState) recordSlice(from math32.Vector3) {
+	if *g.Slice != from {
+		g.History.Record(sliceMove{from, *g.Slice})
+	}
+}
+
+func (g *GuiState) setMeasurements(measurements []volume.Measurement) {
+	g.cancelMeasure()
+	g.Measurements = append([]volume.Measurement(nil), measurements...)
+	g.Dirty = true
+}
@@ -0,0 +1,65 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// stubCommand logs its applies and reverts instead of changing a viewer.
+type stubCommand struct {
+	name string
+	log  *[]string
+}
+
+func (c stubCommand) Apply(g *GuiState, v *volume.Volume)  { *c.log = append(*c.log, "apply "+c.name) }
+func (c stubCommand) Revert(g *GuiState, v *volume.Volume) { *c.log = append(*c.log, "revert "+c.name) }
+
+func TestHistory(t *testing.T) {
+	// The window changes of one drag undo as one; a change after it does not
+	// join them.
+	var h History
+	h.Begin()
+	h.Record(windowChange{[2]float32{40, 400}, [2]float32{50, 400}})
+	h.Record(windowChange{[2]float32{50, 400}, [2]float32{60, 350}})
+	h.End()
+	h.Record(windowChange{[2]float32{60, 350}, [2]float32{70, 350}})
+	if want := []Command{
+		windowChange{[2]float32{40, 400}, [2]float32{60, 350}},
+		windowChange{[2]float32{60, 350}, [2]float32{70, 350}},
+	}; !reflect.DeepEqual(h.done, want) {
+		t.Errorf("after a drag recorded %v, want %v", h.done, want)
+	}
+
+	// Recording after an undo drops what could be redone.
+	var log []string
+	h = History{}
+	h.Record(stubCommand{"a", &log})
+	h.Record(stubCommand{"b", &log})
+	h.Undo(nil, nil)
+	h.Record(stubCommand{"c", &log})
+	h.Redo(nil, nil)
+	if want := []string{"revert b"}; !reflect.DeepEqual(log, want) || len(h.undone) != 0 {
+		t.Errorf("redo after a new command ran %v, want %v", log, want)
+	}
+
+	// Only the latest historyLimit commands are kept.
+	h = History{}
+	for i := 0; i < historyLimit+5; i++ {
+		h.Record(stubCommand{fmt.Sprint(i), &log})
+	}
+	if len(h.done) != historyLimit || h.done[0].(stubCommand).name != "5" {
+		t.Errorf("kept %d commands from %v, want %d from 5", len(h.done), h.done[0], historyLimit)
+	}
+
+	// A batch reverts its commands in reverse order.
+	log = nil
+	h = History{}
+	h.Record(batch{stubCommand{"a", &log}, stubCommand{"b", &log}, stubCommand{"c", &log}})
+	h.Undo(nil, nil)
+	h.Redo(nil, nil)
+	if want := []string{"revert c", "revert b", "revert a", "apply a", "apply b", "apply c"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("batch undo and redo ran %v, want %v", log, want)
+	}
+}
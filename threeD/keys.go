@@ -0,0 +1,244 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/g3n/engine/window"
+)
+
+// KeyBinding is a key together with the modifiers held with it.
+type KeyBinding struct {
+	Key  window.Key
+	Mods window.ModifierKey
+}
+
+// WindowPreset is a named window centre and width, in HU for CT.
+type WindowPreset struct {
+	Name   string
+	Center float32
+	Width  float32
+}
+
+// KeyConfig maps keys, written like "PageUp" or "Ctrl+Shift+Z", to actions.
+// Number keys pick Presets in order: "1" the first, "2" the second and so on.
+// A config file is JSON of the same shape; its bindings are laid over the
+// defaults, "none" unbinding a key, and its presets replace them when given.
+type KeyConfig struct {
+	Bindings map[string]string
+	Presets  []WindowPreset
+}
+
+const (
+	actionSliceNext    = "slice-next"
+	actionSlicePrev    = "slice-prev"
+	actionTiltUp       = "tilt-up"
+	actionTiltDown     = "tilt-down"
+	actionTiltLeft     = "tilt-left"
+	actionTiltRight    = "tilt-right"
+	actionReset        = "reset"
+	actionDebug        = "debug"
+	actionUndo         = "undo"
+	actionRedo         = "redo"
+	actionFinish       = "finish-measurement"
+	actionCancel       = "cancel-measurement"
+	actionCapture      = "capture"
+	actionCaptureClean = "capture-without-overlays"
+	actionPreset       = "preset-"
+	actionNone         = "none"
+)
+
+func DefaultKeyConfig() KeyConfig {
+	c := KeyConfig{
+		Bindings: map[string]string{
+			"PageUp":       actionSliceNext,
+			"PageDown":     actionSlicePrev,
+			"Up":           actionTiltUp,
+			"Down":         actionTiltDown,
+			"Left":         actionTiltLeft,
+			"Right":        actionTiltRight,
+			"R":            actionReset,
+			"D":            actionDebug,
+			"Ctrl+Z":       actionUndo,
+			"Ctrl+Y":       actionRedo,
+			"Ctrl+Shift+Z": actionRedo,
+			"Enter":        actionFinish,
+			"Escape":       actionCancel,
+			"P":            actionCapture,
+			"Shift+P":      actionCaptureClean,
+		},
+		Presets: []WindowPreset{
+			{"Soft tissue", 40, 400},
+			{"Lung", -600, 1500},
+			{"Bone", 400, 1800},
+			{"Brain", 40, 80},
+			{"Liver", 60, 160},
+			{"Mediastinum", 50, 350},
+		},
+	}
+	for i := range c.Presets {
+		c.Bindings[fmt.Sprint(i+1)] = fmt.Sprint(actionPreset, i+1)
+	}
+	return c
+}
+
+// LoadKeyConfig reads the config file at path over the defaults. Fewer
+// presets than the defaults leave the number keys past them unbound, and
+// a binding to a preset the config lacks is an error.
+func LoadKeyConfig(path string) (KeyConfig, error) {
+	c := DefaultKeyConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	var file KeyConfig
+	if err := json.Unmarshal(data, &file); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(file.Presets) > 0 {
+		for i := len(file.Presets); i < len(c.Presets); i++ {
+			delete(c.Bindings, fmt.Sprint(i+1))
+		}
+		c.Presets = file.Presets
+	}
+	for key, action := range file.Bindings {
+		c.Bindings[key] = action
+	}
+	if _, err := c.Actions(); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Actions resolves the bindings into a lookup from key to action.
+func (c KeyConfig) Actions() (map[KeyBinding]string, error) {
+	actions := map[KeyBinding]string{}
+	for spec, action := range c.Bindings {
+		binding, err := ParseKeyBinding(spec)
+		if err != nil {
+			return nil, err
+		}
+		if !knownAction(action, len(c.Presets)) {
+			return nil, fmt.Errorf("unknown action %q for key %q", action, spec)
+		}
+		if action != actionNone {
+			actions[binding] = action
+		}
+	}
+	return actions, nil
+}
+
+// knownAction reports whether action is one, picking one of presets for a
+// preset.
+func knownAction(action string, presets int) bool {
+	switch action {
+	case actionSliceNext, actionSlicePrev, actionTiltUp, actionTiltDown, actionTiltLeft, actionTiltRight,
+		actionReset, actionDebug, actionUndo, actionRedo, actionFinish, actionCancel,
+		actionCapture, actionCaptureClean, actionNone:
+		return true
+	}
+	var n int
+	_, err := fmt.Sscanf(action, actionPreset+"%d", &n)
+	return err == nil && n >= 1 && n <= presets
+}
+
+var keyNames = map[string]window.Key{
+	"PageUp":    window.KeyPageUp,
+	"PageDown":  window.KeyPageDown,
+	"Home":      window.KeyHome,
+	"End":       window.KeyEnd,
+	"Up":        window.KeyUp,
+	"Down":      window.KeyDown,
+	"Left":      window.KeyLeft,
+	"Right":     window.KeyRight,
+	"Enter":     window.KeyEnter,
+	"Escape":    window.KeyEscape,
+	"Space":     window.KeySpace,
+	"Tab":       window.KeyTab,
+	"Backspace": window.KeyBackspace,
+	"Delete":    window.KeyDelete,
+	"Minus":     window.KeyMinus,
+	"Equal":     window.KeyEqual,
+}
+
+func init() {
+	for i := 0; i < 26; i++ {
+		keyNames[string(rune('A'+i))] = window.KeyA + window.Key(i)
+	}
+	for i := 0; i < 10; i++ {
+		keyNames[fmt.Sprint(i)] = window.Key0 + window.Key(i)
+	}
+	for i := 0; i < 12; i++ {
+		keyNames[fmt.Sprint("F", i+1)] = window.KeyF1 + window.Key(i)
+	}
+}
+
+var modNames = map[string]window.ModifierKey{
+	"shift": window.ModShift,
+	"ctrl":  window.ModControl,
+	"alt":   window.ModAlt,
+	"super": window.ModSuper,
+}
+
+// ParseKeyBinding reads a key written as modifiers and a key name joined by
+// '+', such as "Ctrl+Z". Names are case insensitive.
+func ParseKeyBinding(spec string) (KeyBinding, error) {
+	parts := strings.Split(spec, "+")
+	var b KeyBinding
+	for _, mod := range parts[:len(parts)-1] {
+		m, ok := modNames[strings.ToLower(strings.TrimSpace(mod))]
+		if !ok {
+			return b, fmt.Errorf("unknown modifier %q in key %q", mod, spec)
+		}
+		b.Mods |= m
+	}
+	name := strings.TrimSpace(parts[len(parts)-1])
+	for n, key := range keyNames {
+		if strings.EqualFold(n, name) {
+			b.Key = key
+			return b, nil
+		}
+	}
+	return b, fmt.Errorf("unknown key %q", spec)
+}
+
+// perform carries out a bound action; slice steps act on vp.
+func (g *GuiState) perform(action string, v *volume.Volume, presets []WindowPreset, vp *Viewport2D) {
+	const tiltStep = 1
+	switch action {
+	case actionSliceNext:
+		g.step(*v, vp.Axis, 1)
+	case actionSlicePrev:
+		g.step(*v, vp.Axis, -1)
+	case actionTiltUp:
+		g.tilt(*v, 0, -tiltStep)
+	case actionTiltDown:
+		g.tilt(*v, 0, tiltStep)
+	case actionTiltLeft:
+		g.tilt(*v, 1, -tiltStep)
+	case actionTiltRight:
+		g.tilt(*v, 1, tiltStep)
+	case actionReset:
+		g.reset(*v)
+	case actionDebug:
+		g.toggleDebug()
+	case actionUndo:
+		g.History.Undo(g, v)
+	case actionRedo:
+		g.History.Redo(g, v)
+	case actionFinish:
+		g.finishMeasure()
+	case actionCancel:
+		g.cancelMeasure()
+	default:
+		var n int
+		if _, err := fmt.Sscanf(action, actionPreset+"%d", &n); err == nil && n >= 1 && n <= len(presets) {
+			change := windowChange{[2]float32{v.DcmData.Window, v.DcmData.Level}, [2]float32{presets[n-1].Center, presets[n-1].Width}}
+			change.Apply(g, v)
+			g.History.Record(change)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"fmt"
+	"log"
+
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+)
+
+// Landmark colours on the primary volume and on the fusion volume.
+var landmarkColors = [2]*math32.Color{{0, 1, 1}, {1, 0, 1}}
+
+// landmarkSize is the span of a landmark's cross, in mm.
+const landmarkSize = 6
+
+// Landmarks are the points paired to register the fusion volume by hand,
+// placed in turn: one on the primary, then its match on the fusion volume
+// as shown. Residuals are those of the last fit, in mm, until a landmark
+// changes.
+type Landmarks struct {
+	Active    bool
+	Kind      volume.TransformKind
+	Fixed     []math32.Vector3
+	Moving    []math32.Vector3
+	Residuals []float32
+}
+
+// Place adds p as the next landmark, on the fusion volume when the last one
+// on the primary is unmatched.
+func (l *Landmarks) Place(p math32.Vector3) {
+	if len(l.Fixed) > len(l.Moving) {
+		l.Moving = append(l.Moving, p)
+	} else {
+		l.Fixed = append(l.Fixed, p)
+	}
+	l.Residuals = nil
+}
+
+// Undo removes the landmark placed last.
+func (l *Landmarks) Undo() {
+	if len(l.Fixed) > len(l.Moving) {
+		l.Fixed = l.Fixed[:len(l.Fixed)-1]
+	} else if len(l.Moving) > 0 {
+		l.Moving = l.Moving[:len(l.Moving)-1]
+	}
+	l.Residuals = nil
+}
+
+// fitLandmarks registers the fusion volume onto the primary v by the paired
+// landmarks and returns the fit.
+func (g *GuiState) fitLandmarks(v volume.Volume) (volume.LandmarkFit, error) {
+	l := &g.Landmarks
+	fit, err := volume.FitLandmarks(l.Moving, l.Fixed[:len(l.Moving)], l.Kind)
+	if err != nil {
+		return fit, err
+	}
+	g.applyRegistration(v, fit.Matrix, l.Kind)
+	l.Residuals = fit.Residuals
+	return fit, nil
+}
+
+// landmarkReport sums fit up in a line, and logs the residual of every pair.
+func landmarkReport(fit volume.LandmarkFit) string {
+	var most float32
+	for i, r := range fit.Residuals {
+		log.Printf("landmark %d: %.2f mm", i+1, r)
+		most = math32.Max(most, r)
+	}
+	report := fmt.Sprintf("%d pairs  RMS %.2f mm  max %.2f mm", len(fit.Residuals), fit.RMS, most)
+	if fit.Scale != 1 {
+		report += fmt.Sprintf("  scale %.3f", fit.Scale)
+	}
+	return report
+}
+
+// SetLandmarks draws the landmarks lying on this viewport's plane as
+// crosses, numbered, with a prime on the fusion volume's.
+func (vp *Viewport2D) SetLandmarks(l Landmarks) {
+	if vp.Frame.ImageSizeInMm == nil {
+		return
+	}
+	w, h := vp.imageSize()
+	min := vp.Frame.Box2f.Min
+	for side, points := range [2][]math32.Vector3{l.Fixed, l.Moving} {
+		for i, p := range points {
+			if !(volume.Measurement{Points: []math32.Vector3{p}}).OnPlane(vp.Frame, vp.VoxelSize/2) {
+				continue
+			}
+			c := vp.Frame.PatientToPlane(&p)
+			x, y := c.X-min.X-w/2, h/2-(c.Y-min.Y)
+			positions := math32.NewArrayF32(0, 0)
+			positions.Append(
+				x-landmarkSize/2, y, 2, x+landmarkSize/2, y, 2,
+				x, y-landmarkSize/2, 2, x, y+landmarkSize/2, 2)
+			geom := geometry.NewGeometry()
+			geom.AddVBO(gls.NewVBO(positions).AddAttrib(gls.VertexPosition))
+			vp.marks.Add(graphic.NewLines(geom, material.NewStandard(landmarkColors[side])))
+
+			text := fmt.Sprint(i + 1)
+			if side == 1 {
+				text += "'"
+				if i < len(l.Residuals) {
+					text += fmt.Sprintf(" %.1f mm", l.Residuals[i])
+				}
+			}
+			label := gui.NewLabel(text)
+			label.SetColor(landmarkColors[side])
+			vp.root.Add(label)
+			vp.markLabels = append(vp.markLabels, label)
+			vp.markAnchors = append(vp.markAnchors, *math32.NewVector2(c.X-min.X, c.Y-min.Y))
+		}
+	}
+	vp.placeMarks()
+}
+
+// placeLandmarkRows adds to the fusion panel, from y down, the switch
+// placing landmarks, whether the fit may scale, and the buttons fitting,
+// undoing and clearing them, over the fit's report.
+func placeLandmarkRows(panel *gui.Panel, g *GuiState, v *volume.Volume, y float32) {
+	l := &g.Landmarks
+	report := gui.NewLabel("")
+	on := gui.NewCheckBox("Landmarks")
+	on.SetPosition(4, y+4)
+	on.Subscribe(gui.OnChange, func(name string, ev interface{}) {
+		if l.Active = on.Value(); l.Active {
+			g.pickTool(false, 0)
+		}
+	})
+	panel.Add(on)
+	scale := gui.NewCheckBox("Scale")
+	scale.SetPosition(90, y+4)
+	scale.Subscribe(gui.OnChange, func(name string, ev interface{}) {
+		l.Kind = volume.Rigid
+		if scale.Value() {
+			l.Kind = volume.Similarity
+		}
+	})
+	panel.Add(scale)
+
+	x := float32(4)
+	add := func(label string, cb func()) {
+		b := gui.NewButton(label)
+		b.SetPosition(x, y+26)
+		b.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+			cb()
+			g.Dirty = true
+		})
+		panel.Add(b)
+		x += b.Width() + 4
+	}
+	add("Fit", func() {
+		fit, err := g.fitLandmarks(*v)
+		if err != nil {
+			report.SetText(err.Error())
+			return
+		}
+		report.SetText(landmarkReport(fit))
+	})
+	add("Undo", func() {
+		l.Undo()
+		report.SetText("")
+	})
+	add("Clear", func() {
+		*l = Landmarks{Active: l.Active, Kind: l.Kind}
+		report.SetText("")
+	})
+	report.SetPosition(4, y+56)
+	panel.Add(report)
+}
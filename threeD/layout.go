@@ -0,0 +1,148 @@
+package threeD
+
+import (
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/math32"
+)
+
+// dividerGrab is how close, in pixels, the cursor must be to a pane divider
+// to start dragging it.
+const dividerGrab = 4
+
+// Pane is a window rectangle in cursor coordinates: origin top left, pixels.
+type Pane struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// Contains reports whether window point x, y lies inside the pane.
+func (p Pane) Contains(x float32, y float32) bool {
+	return x >= float32(p.X) && x < float32(p.X+p.Width) && y >= float32(p.Y) && y < float32(p.Y+p.Height)
+}
+
+// Viewport returns the pane as OpenGL viewport arguments, origin bottom left.
+func (p Pane) Viewport(windowHeight int) (int32, int32, int32, int32) {
+	return int32(p.X), int32(windowHeight - p.Y - p.Height), int32(p.Width), int32(p.Height)
+}
+
+// Aspect returns the pane width over height.
+func (p Pane) Aspect() float32 {
+	if p.Height == 0 {
+		return 1
+	}
+	return float32(p.Width) / float32(p.Height)
+}
+
+// NDC converts window point x, y to normalized device coordinates of the pane.
+func (p Pane) NDC(x float32, y float32) (float32, float32) {
+	return 2*(x-float32(p.X))/float32(p.Width) - 1, 1 - 2*(y-float32(p.Y))/float32(p.Height)
+}
+
+// Window converts normalized device coordinates of the pane to a window
+// point, undoing NDC.
+func (p Pane) Window(sx float32, sy float32) (float32, float32) {
+	return float32(p.X) + (sx+1)/2*float32(p.Width), float32(p.Y) + (1-sy)/2*float32(p.Height)
+}
+
+// Layout is the classic 2x2 MPR arrangement: axial top left, coronal top
+// right, sagittal bottom left and the 3D overview bottom right. SplitX and
+// SplitY are the fractions of the window taken by the left column and the
+// top row.
+type Layout struct {
+	SplitX float32
+	SplitY float32
+	Width  int
+	Height int
+
+	dragX bool
+	dragY bool
+}
+
+func NewLayout() *Layout {
+	return &Layout{SplitX: 0.5, SplitY: 0.5}
+}
+
+func (l *Layout) Resize(width int, height int) {
+	l.Width = width
+	l.Height = height
+}
+
+func (l *Layout) splits() (int, int) {
+	return int(l.SplitX * float32(l.Width)), int(l.SplitY * float32(l.Height))
+}
+
+// Panes returns the axial, coronal, sagittal and overview panes.
+func (l *Layout) Panes() (Pane, Pane, Pane, Pane) {
+	sx, sy := l.splits()
+	return Pane{0, 0, sx, sy},
+		Pane{sx, 0, l.Width - sx, sy},
+		Pane{0, sy, sx, l.Height - sy},
+		Pane{sx, sy, l.Width - sx, l.Height - sy}
+}
+
+func (l *Layout) near(x float32, y float32) (bool, bool) {
+	sx, sy := l.splits()
+	return abs(x-float32(sx)) <= dividerGrab, abs(y-float32(sy)) <= dividerGrab
+}
+
+// Near reports whether window point x, y is close enough to a divider to grab it.
+func (l *Layout) Near(x float32, y float32) bool {
+	nx, ny := l.near(x, y)
+	return nx || ny
+}
+
+// Grab starts dragging the dividers near window point x, y and reports
+// whether any was grabbed.
+func (l *Layout) Grab(x float32, y float32) bool {
+	l.dragX, l.dragY = l.near(x, y)
+	return l.dragX || l.dragY
+}
+
+// Drag moves the grabbed dividers to window point x, y and reports whether
+// the layout changed.
+func (l *Layout) Drag(x float32, y float32) bool {
+	if l.dragX {
+		l.SplitX = clampSplit(x / float32(l.Width))
+	}
+	if l.dragY {
+		l.SplitY = clampSplit(y / float32(l.Height))
+	}
+	return l.dragX || l.dragY
+}
+
+func (l *Layout) Release() {
+	l.dragX = false
+	l.dragY = false
+}
+
+func clampSplit(f float32) float32 {
+	if f < 0.1 {
+		return 0.1
+	}
+	if f > 0.9 {
+		return 0.9
+	}
+	return f
+}
+
+func abs(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
+
+// clearPane fills pane with c, leaving the rest of the window untouched.
+func clearPane(gs *gls.GLS, p Pane, windowHeight int, c *math32.Color) {
+	x, y, w, h := p.Viewport(windowHeight)
+	if w <= 0 || h <= 0 {
+		return
+	}
+	gs.Enable(gls.SCISSOR_TEST)
+	gs.Scissor(x, y, uint32(w), uint32(h))
+	gs.ClearColor(c.R, c.G, c.B, 1)
+	gs.Clear(gls.DEPTH_BUFFER_BIT | gls.COLOR_BUFFER_BIT)
+	gs.Disable(gls.SCISSOR_TEST)
+}
@@ -0,0 +1,77 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"log"
+
+	"github.com/g3n/engine/gui"
+)
+
+// placeLUTMenu adds the menu colouring the 2D images with a lookup table,
+// one of the built-in ones or initial, and starts with initial. Gray, the
+// built-in table included, is the nil entry and shows no colour bar.
+func placeLUTMenu(scene *gui.Panel, g *GuiState, v *volume.Volume, initial *volume.LUT) {
+	if gray, err := volume.BuiltinLUT(volume.LUTNames[0]); err == nil && initial != nil && *initial == *gray {
+		initial = nil
+	}
+	g.luts = []*volume.LUT{nil}
+	for _, name := range volume.LUTNames[1:] {
+		lut, err := volume.BuiltinLUT(name)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		g.luts = append(g.luts, lut)
+	}
+	if initial != nil && g.lutNamed(initial.Name) == nil {
+		g.luts = append(g.luts, initial)
+	}
+	menu := gui.NewDropDown(130, gui.NewImageLabel(volume.LUTNames[0]))
+	menu.SetPosition(280, 300)
+	for _, lut := range g.luts {
+		name := volume.LUTNames[0]
+		if lut != nil {
+			name = lut.Name
+		}
+		menu.Add(gui.NewImageLabel(name))
+	}
+	menu.Subscribe(gui.OnChange, func(name string, ev interface{}) {
+		if lut := g.luts[menu.SelectedPos()]; lut != v.DcmData.LUT {
+			g.setLUT(v, lut)
+		}
+	})
+	scene.Add(menu)
+	g.LUTMenu = menu
+	if initial != nil {
+		g.selectLUT(g.lutNamed(initial.Name))
+	}
+}
+
+// selectLUT shows lut as chosen in the menu.
+func (g *GuiState) selectLUT(lut *volume.LUT) {
+	if g.LUTMenu == nil {
+		return
+	}
+	for i, l := range g.luts {
+		if l == lut {
+			g.LUTMenu.SelectPos(i)
+		}
+	}
+}
+
+// lutNamed finds a table of the menu by name, gray if none has it.
+func (g *GuiState) lutNamed(name string) *volume.LUT {
+	for _, lut := range g.luts {
+		if lut != nil && lut.Name == name {
+			return lut
+		}
+	}
+	return nil
+}
+
+func lutName(lut *volume.LUT) string {
+	if lut == nil {
+		return ""
+	}
+	return lut.Name
+}
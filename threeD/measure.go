@@ -0,0 +1,250 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"fmt"
+	"log"
+
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+)
+
+var measureColor = &math32.Color{1, 1, 0}
+
+// Measuring holds the measurement tool picked in the toolbar and the
+// measurement being drawn with it.
+type Measuring struct {
+	Active  bool
+	Kind    volume.MeasureKind
+	drawing *volume.Measurement
+	view    *Viewport2D
+	anchor  math32.Vector3
+}
+
+func placeMeasureButtons(scene *gui.Panel, g *GuiState) {
+	tools := []struct {
+		label string
+		kind  volume.MeasureKind
+	}{
+		{"Line", volume.Distance},
+		{"Angle", volume.Angle},
+		{"Poly", volume.Polyline},
+		{"Ellipse", volume.Ellipse},
+		{"Free", volume.Freehand},
+	}
+	x := float32(10)
+	add := func(label string, cb func()) {
+		b := gui.NewButton(label)
+		b.SetPosition(x, 120)
+		b.Subscribe(gui.OnClick, func(name string, ev interface{}) { cb() })
+		scene.Add(b)
+		x += b.Width() + 4
+	}
+	add("Nav", func() { g.pickTool(false, 0) })
+	for _, t := range tools {
+		kind := t.kind
+		add(t.label, func() { g.pickTool(true, kind) })
+	}
+	add("Clear", func() {
+		g.editMeasurements(nil)
+	})
+}
+
+// placeReportButtons adds buttons saving the measurements to, and loading
+// them from, the structured report at path.
+func placeReportButtons(scene *gui.Panel, g *GuiState, v volume.Volume, path string) {
+	save := gui.NewButton("Save SR")
+	save.SetPosition(60, 150)
+	save.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		g.finishMeasure()
+		if err := volume.WriteSR(path, v, g.Measurements); err != nil {
+			log.Println("saving measurements:", err)
+			return
+		}
+		log.Println("measurements saved to", path)
+	})
+	scene.Add(save)
+	load := gui.NewButton("Load SR")
+	load.SetPosition(64+save.Width(), 150)
+	load.Subscribe(gui.OnClick, func(name string, ev interface{}) { g.loadReport(path) })
+	scene.Add(load)
+}
+
+// loadReport replaces the measurements with those of the report at path.
+func (g *GuiState) loadReport(path string) {
+	measurements, err := volume.ReadSR(path)
+	if err != nil {
+		log.Println("loading measurements:", err)
+		return
+	}
+	g.editMeasurements(measurements)
+}
+
+// editMeasurements replaces the measurements, undoably.
+func (g *GuiState) editMeasurements(measurements []volume.Measurement) {
+	edit := annotationEdit{g.Measurements, measurements}
+	edit.Apply(g, nil)
+	g.History.Record(edit)
+}
+
+func (g *GuiState) pickTool(active bool, kind volume.MeasureKind) {
+	g.finishMeasure()
+	g.Measuring.Active = active
+	g.Measuring.Kind = kind
+}
+
+// measureDown starts a measurement on vp at patient point p, or fixes the
+// next point of an angle or polyline.
+func (g *GuiState) measureDown(vp *Viewport2D, p *math32.Vector3) {
+	m := &g.Measuring
+	if m.drawing != nil && m.view != vp {
+		g.finishMeasure()
+	}
+	if m.drawing == nil {
+		m.drawing = &volume.Measurement{Kind: m.Kind, Points: []math32.Vector3{*p, *p}}
+		if m.Kind == volume.Ellipse {
+			*m.drawing = volume.EllipseFromCorners(vp.Frame, p, p)
+		}
+		m.view = vp
+		m.anchor = *p
+		g.Dirty = true
+		return
+	}
+	m.drawing.Points[len(m.drawing.Points)-1] = *p
+	if m.Kind == volume.Angle && len(m.drawing.Points) == 3 {
+		g.finishMeasure()
+		return
+	}
+	m.drawing.Points = append(m.drawing.Points, *p)
+	g.Dirty = true
+}
+
+// measureMove drags the loose end of the measurement to p; freehand outlines
+// grow by a point every pixel or so.
+func (g *GuiState) measureMove(p *math32.Vector3) {
+	d := g.Measuring.drawing
+	if d == nil {
+		return
+	}
+	last := &d.Points[len(d.Points)-1]
+	if d.Kind == volume.Ellipse {
+		*d = volume.EllipseFromCorners(g.Measuring.view.Frame, &g.Measuring.anchor, p)
+	} else if d.Kind == volume.Freehand {
+		if p.DistanceTo(last) < g.Measuring.view.VoxelSize {
+			return
+		}
+		d.Points = append(d.Points, *p)
+	} else {
+		*last = *p
+	}
+	g.Dirty = true
+}
+
+// measureUp finishes the measurements drawn with a single drag.
+func (g *GuiState) measureUp() {
+	if d := g.Measuring.drawing; d != nil && (d.Kind == volume.Distance || d.Kind == volume.Ellipse || d.Kind == volume.Freehand) {
+		g.finishMeasure()
+	}
+}
+
+// finishMeasure keeps the measurement being drawn if it has enough points.
+func (g *GuiState) finishMeasure() {
+	d := g.Measuring.drawing
+	if d == nil {
+		return
+	}
+	g.Measuring.drawing = nil
+	g.Measuring.view = nil
+	g.Dirty = true
+	if d.Kind == volume.Polyline {
+		d.Points = d.Points[:len(d.Points)-1]
+	}
+	if d.Complete() {
+		g.editMeasurements(append(append([]volume.Measurement(nil), g.Measurements...), *d))
+	}
+}
+
+// cancelMeasure drops the measurement being drawn.
+func (g *GuiState) cancelMeasure() {
+	g.Measuring.drawing = nil
+	g.Measuring.view = nil
+	g.Dirty = true
+}
+
+// shownMeasurements returns the finished measurements plus the one being drawn.
+func (g *GuiState) shownMeasurements() []volume.Measurement {
+	if g.Measuring.drawing == nil {
+		return g.Measurements
+	}
+	return append(append([]volume.Measurement{}, g.Measurements...), *g.Measuring.drawing)
+}
+
+func measureText(m volume.Measurement, v volume.Volume) string {
+	switch m.Kind {
+	case volume.Angle:
+		if len(m.Points) < 3 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f°", m.Degrees())
+	case volume.Ellipse, volume.Freehand:
+		s := m.Stats(v)
+		data := v.DcmData
+		return fmt.Sprintf("%.1f mm²  %s ± %s\nmin %s  max %s  %d px",
+			s.Area, data.FormatValue(s.Mean), data.FormatValue(s.Std), data.FormatValue(s.Min), data.FormatValue(s.Max), s.Count)
+	}
+	return fmt.Sprintf("%.1f mm", m.Length())
+}
+
+// SetMeasurements draws the measurements lying on this viewport's plane.
+func (vp *Viewport2D) SetMeasurements(measurements []volume.Measurement, v volume.Volume) {
+	vp.marks.RemoveAll(true)
+	for _, l := range vp.markLabels {
+		vp.root.Remove(l)
+		l.Dispose()
+	}
+	vp.markLabels = nil
+	vp.markAnchors = nil
+	if vp.Frame.ImageSizeInMm == nil {
+		return
+	}
+	w, h := vp.imageSize()
+	min := vp.Frame.Box2f.Min
+	for _, m := range measurements {
+		if !m.OnPlane(vp.Frame, vp.VoxelSize/2) {
+			continue
+		}
+		outline := m.Outline(vp.Frame)
+		if m.IsRegion() {
+			outline = append(outline, outline[0])
+		}
+		geom := geometry.NewGeometry()
+		positions := math32.NewArrayF32(0, 0)
+		for _, p := range outline {
+			positions.Append(p.X-min.X-w/2, h/2-(p.Y-min.Y), 2)
+		}
+		geom.AddVBO(gls.NewVBO(positions).AddAttrib(gls.VertexPosition))
+		vp.marks.Add(graphic.NewLineStrip(geom, material.NewStandard(measureColor)))
+
+		label := gui.NewLabel(measureText(m, v))
+		label.SetColor(measureColor)
+		vp.root.Add(label)
+		vp.markLabels = append(vp.markLabels, label)
+		last := outline[len(outline)-1]
+		vp.markAnchors = append(vp.markAnchors, *math32.NewVector2(last.X-min.X, last.Y-min.Y))
+	}
+	vp.placeMarks()
+}
+
+// placeMarks keeps the measurement labels beside their measurements as the
+// view zooms and pans, hiding those scrolled out of the pane.
+func (vp *Viewport2D) placeMarks() {
+	for i, l := range vp.markLabels {
+		x, y := vp.ScreenAt(vp.markAnchors[i].X, vp.markAnchors[i].Y)
+		l.SetPosition(x+6, y+4)
+		l.SetVisible(vp.Pane.Contains(x, y))
+	}
+}
@@ -0,0 +1,164 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+
+	"github.com/g3n/engine/math32"
+)
+
+func dims(v volume.Volume) [3]int {
+	return [3]int{v.DcmData.Cols, v.DcmData.Rows, v.DcmData.Depth}
+}
+
+func clampSlice(f float32, size int) float32 {
+	return math32.Clamp(f, 0, float32(size-1))
+}
+
+// refresh re-cuts the planes whose slice index changed since the last cut,
+// all of them the first time, and redraws the scene, viewports and crosshairs.
+func (g *GuiState) refresh(v volume.Volume) {
+	if g.cut == nil {
+		g.cut = math32.NewVector3(-1, -1, -1)
+	}
+	if int(g.Slice.Z) != int(g.cut.Z) {
+		updateAxial(g, v)
+		g.AxialNode = Draw(g.Axial, v, g.AxialNode, g.AxialView.Color)
+		g.AxialView.SetFrame(g.Axial)
+	}
+	if int(g.Slice.Y) != int(g.cut.Y) {
+		updateCoronal(g, v)
+		g.CoronalNode = Draw(g.Coronal, v, g.CoronalNode, g.CoronalView.Color)
+		g.CoronalView.SetFrame(g.Coronal)
+	}
+	if int(g.Slice.X) != int(g.cut.X) {
+		updateSagittal(g, v)
+		g.SagittallNode = Draw(g.Sagittal, v, g.SagittallNode, g.SagittalView.Color)
+		g.SagittalView.SetFrame(g.Sagittal)
+	}
+	g.cut.Copy(g.Slice)
+	g.DebugNode = DrawDebug(g.Sagittal, g.DebugNode, g.Debug)
+
+	g.AxialView.SetCrosshair(g.CoronalView, g.SagittalView)
+	g.CoronalView.SetCrosshair(g.AxialView, g.SagittalView)
+	g.SagittalView.SetCrosshair(g.AxialView, g.CoronalView)
+	for _, vp := range g.Views() {
+		vp.SetMeasurements(g.shownMeasurements(), v)
+		vp.SetLandmarks(g.Landmarks)
+		vp.SetColorBar(v.DcmData)
+	}
+}
+
+// moveTo centres the crosshair on patient point p, moving every plane except
+// the one perpendicular to voxel axis keep.
+func (g *GuiState) moveTo(v volume.Volume, p *math32.Vector3, keep int) {
+	toVoxel := math32.NewMatrix4()
+	toVoxel.GetInverse(v.DcmData.Calibration)
+	index := math32.NewVec3().Copy(p).ApplyMatrix4(toVoxel)
+	size := dims(v)
+	from := *g.Slice
+	for i := 0; i < 3; i++ {
+		if i != keep {
+			g.Slice.SetComponent(i, clampSlice(index.Component(i), size[i]))
+		}
+	}
+	g.syncSliders(v)
+	g.recordSlice(from)
+	g.Dirty = true
+}
+
+// step moves the plane perpendicular to voxel axis by delta voxels.
+func (g *GuiState) step(v volume.Volume, axis int, delta float32) {
+	size := dims(v)
+	from := *g.Slice
+	g.Slice.SetComponent(axis, clampSlice(float32(int(g.Slice.Component(axis)))+delta, size[axis]))
+	g.syncSliders(v)
+	g.recordSlice(from)
+	g.Dirty = true
+}
+
+func (g *GuiState) syncSliders(v volume.Volume) {
+	if g.XSlider == nil {
+		return
+	}
+	// The sliders report their new values, which would round the slice.
+	slice := math32.NewVec3().Copy(g.Slice)
+	g.syncing = true
+	g.XSlider.SetValue(norm(slice.X, float32(v.DcmData.Cols)))
+	g.YSlider.SetValue(norm(slice.Y, float32(v.DcmData.Rows)))
+	g.ZSlider.SetValue(norm(slice.Z, float32(v.DcmData.Depth)))
+	g.syncing = false
+	g.Slice.Copy(slice)
+}
+
+// slide applies a slider's change to the slice, recording it unless the
+// slider is only following the slice.
+func (g *GuiState) slide(change func()) {
+	if g.syncing {
+		change()
+		return
+	}
+	from := *g.Slice
+	change()
+	g.recordSlice(from)
+	g.Dirty = true
+}
+
+// viewAt returns the 2D viewport under window point x, y.
+func (g *GuiState) viewAt(x float32, y float32) *Viewport2D {
+	for _, vp := range g.Views() {
+		if vp.Pane.Contains(x, y) {
+			return vp
+		}
+	}
+	return nil
+}
+
+// reset centres the slices, levels the oblique plane and fits the views.
+func (g *GuiState) reset(v volume.Volume) {
+	size := dims(v)
+	slice, oblique := *g.Slice, *g.Oblique
+	for i := 0; i < 3; i++ {
+		g.Slice.SetComponent(i, float32(size[i]/2))
+	}
+	g.syncSliders(v)
+	g.Oblique.Identity()
+	var cmds batch
+	if *g.Slice != slice {
+		cmds = append(cmds, sliceMove{slice, *g.Slice})
+	}
+	if *g.Oblique != oblique {
+		cmds = append(cmds, planeRotation{oblique, *g.Oblique})
+	}
+	if len(cmds) > 0 {
+		g.History.Record(cmds)
+	}
+	g.Custom = volume.SliceFrame{}
+	if g.CustomNode != nil {
+		g.CustomNode.RemoveAll(true)
+	}
+	for _, vp := range g.Views() {
+		vp.Fit()
+	}
+	g.Dirty = true
+}
+
+func (g *GuiState) toggleDebug() {
+	g.Debug = !g.Debug
+	if g.DebugBox != nil && g.DebugBox.Value() != g.Debug {
+		g.DebugBox.SetValue(g.Debug)
+	}
+	g.Dirty = true
+}
+
+// tilt rotates the oblique plane by degrees about its own x (axis 0) or y
+// (axis 1) direction and shows it in the overview.
+func (g *GuiState) tilt(v volume.Volume, axis int, degrees float32) {
+	dir := math32.NewVector3(0, 0, 0)
+	dir.SetComponent(axis, 1)
+	rotation := math32.NewMatrix4().MakeRotationAxis(dir, math32.DegToRad(degrees))
+	from := *g.Oblique
+	g.Oblique.Multiply(rotation)
+	g.History.Record(planeRotation{from, *g.Oblique})
+	updateFree(g, v)
+	g.Dirty = true
+}
@@ -0,0 +1,79 @@
+package threeD
+
+/*
+// Framebuffer objects are missing from the gls wrapper but loaded by its
+// glapi.c, whose entry points are visible to the whole binary.
+extern void glGenFramebuffers(int n, unsigned int *framebuffers);
+extern void glDeleteFramebuffers(int n, const unsigned int *framebuffers);
+extern void glBindFramebuffer(unsigned int target, unsigned int framebuffer);
+extern unsigned int glCheckFramebufferStatus(unsigned int target);
+extern void glGenRenderbuffers(int n, unsigned int *renderbuffers);
+extern void glDeleteRenderbuffers(int n, const unsigned int *renderbuffers);
+extern void glBindRenderbuffer(unsigned int target, unsigned int renderbuffer);
+extern void glRenderbufferStorage(unsigned int target, unsigned int internalformat, int width, int height);
+extern void glFramebufferRenderbuffer(unsigned int target, unsigned int attachment, unsigned int renderbuffertarget, unsigned int renderbuffer);
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/math32"
+)
+
+// offscreen is a framebuffer object with colour and depth renderbuffers.
+type offscreen struct {
+	fbo    C.uint
+	color  C.uint
+	depth  C.uint
+	width  int
+	height int
+}
+
+func newOffscreen(width int, height int) (*offscreen, error) {
+	o := &offscreen{width: width, height: height}
+	C.glGenFramebuffers(1, &o.fbo)
+	C.glBindFramebuffer(gls.FRAMEBUFFER, o.fbo)
+	C.glGenRenderbuffers(1, &o.color)
+	C.glBindRenderbuffer(gls.RENDERBUFFER, o.color)
+	C.glRenderbufferStorage(gls.RENDERBUFFER, gls.RGBA8, C.int(width), C.int(height))
+	C.glFramebufferRenderbuffer(gls.FRAMEBUFFER, gls.COLOR_ATTACHMENT0, gls.RENDERBUFFER, o.color)
+	C.glGenRenderbuffers(1, &o.depth)
+	C.glBindRenderbuffer(gls.RENDERBUFFER, o.depth)
+	C.glRenderbufferStorage(gls.RENDERBUFFER, gls.DEPTH24_STENCIL8, C.int(width), C.int(height))
+	C.glFramebufferRenderbuffer(gls.FRAMEBUFFER, gls.DEPTH_STENCIL_ATTACHMENT, gls.RENDERBUFFER, o.depth)
+	C.glBindRenderbuffer(gls.RENDERBUFFER, 0)
+	if status := C.glCheckFramebufferStatus(gls.FRAMEBUFFER); status != gls.FRAMEBUFFER_COMPLETE {
+		o.dispose()
+		return nil, fmt.Errorf("framebuffer incomplete: %#x", uint32(status))
+	}
+	return o, nil
+}
+
+func (o *offscreen) dispose() {
+	C.glBindFramebuffer(gls.FRAMEBUFFER, 0)
+	C.glDeleteRenderbuffers(1, &o.color)
+	C.glDeleteRenderbuffers(1, &o.depth)
+	C.glDeleteFramebuffers(1, &o.fbo)
+}
+
+// render clears the framebuffer to background, draws with draw and reads the
+// pixels back, top row first.
+func (o *offscreen) render(gs *gls.GLS, background *math32.Color, draw func()) *image.RGBA {
+	C.glBindFramebuffer(gls.FRAMEBUFFER, o.fbo)
+	pane := Pane{0, 0, o.width, o.height}
+	clearPane(gs, pane, o.height, background)
+	gs.Viewport(pane.Viewport(o.height))
+	draw()
+	pixels := gs.ReadPixels(0, 0, o.width, o.height, gls.RGBA, gls.UNSIGNED_BYTE)
+	C.glBindFramebuffer(gls.FRAMEBUFFER, 0)
+
+	img := image.NewRGBA(image.Rect(0, 0, o.width, o.height))
+	stride := 4 * o.width
+	for y := 0; y < o.height; y++ {
+		copy(img.Pix[y*stride:(y+1)*stride], pixels[(o.height-1-y)*stride:(o.height-y)*stride])
+	}
+	return img
+}
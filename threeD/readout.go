@@ -0,0 +1,58 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"fmt"
+	"math"
+
+	"github.com/g3n/engine/camera"
+	"github.com/g3n/engine/math32"
+)
+
+// pick casts a ray from the camera through normalized device coordinates
+// sx, sy and returns the nearest point where it crosses one of the displayed
+// planes inside the volume.
+func pick(cam *camera.Camera, g *GuiState, v volume.Volume, sx float32, sy float32) (*math32.Vector3, bool) {
+	var origin math32.Vector3
+	world := cam.MatrixWorld()
+	origin.SetFromMatrixPosition(&world)
+	dir := cam.Unproject(math32.NewVector3(sx, sy, 0.5)).Sub(&origin).Normalize()
+	ray := math32.NewRay(&origin, dir)
+
+	var best *math32.Vector3
+	bestT := float32(math.MaxFloat32)
+	for _, frame := range []volume.SliceFrame{g.Axial, g.Coronal, g.Sagittal} {
+		if frame.RotatedFrame.Plane == nil {
+			continue
+		}
+		t := ray.DistanceToPlane(frame.RotatedFrame.Plane)
+		if math.IsNaN(float64(t)) || t >= bestT {
+			continue
+		}
+		p := ray.At(t, nil)
+		if _, inside := v.VoxelIndex(p); inside {
+			best, bestT = p, t
+		}
+	}
+	return best, best != nil
+}
+
+func readoutText(v volume.Volume, p *math32.Vector3) string {
+	index, inside := v.VoxelIndex(p)
+	if !inside {
+		return ""
+	}
+	return fmt.Sprintf("voxel (%d, %d, %d)  LPS (%.1f, %.1f, %.1f) mm  %s",
+		index[0], index[1], index[2], p.X, p.Y, p.Z, v.DcmData.FormatValue(v.Value(index)))
+}
+
+func labelsText(name string, frame volume.SliceFrame) string {
+	l := frame.Labels()
+	return fmt.Sprintf("%s  left %s  right %s  top %s  bottom %s", name, l.Left, l.Right, l.Top, l.Bottom)
+}
+
+func orientationText(g *GuiState) string {
+	return labelsText("Axial", g.Axial) + "\n" +
+		labelsText("Coronal", g.Coronal) + "\n" +
+		labelsText("Sagittal", g.Sagittal)
+}
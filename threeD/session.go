@@ -0,0 +1,180 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+)
+
+// Session is everything needed to reproduce a view: what was loaded, where
+// the planes are, how the images are windowed and zoomed, the layout and the
+// measurements drawn. Values is the mode PET values are shown in, empty when
+// not recorded, and the window is in its units.
+type Session struct {
+	Input        string
+	Slice        [3]float32
+	Debug        bool
+	Oblique      [16]float32
+	Values       string
+	WindowCenter float32
+	WindowWidth  float32
+	LUT          string
+	SplitX       float32
+	SplitY       float32
+	Views        map[string]ViewState
+	Measurements []volume.Measurement
+}
+
+// ViewState is the zoom and pan of one 2D viewport.
+type ViewState struct {
+	Zoom   float32
+	Center [2]float32
+}
+
+// Options configure Init. Session, when set, is restored once the viewer
+// is up.
+type Options struct {
+	Input       string
+	SRPath      string
+	SessionPath string
+	Session     *Session
+	Keys        KeyConfig
+	Capture     CaptureRequest
+	Cine        CineRequest
+	// TransferPath is where the transfer function editor saves and loads,
+	// and the function volume rendering starts with when it exists.
+	TransferPath string
+	Surface      SurfaceRequest
+	// LUT colours the images from the start, gray when nil.
+	LUT *volume.LUT
+	// Fusion, when set, is blended over the images.
+	Fusion *Fusion
+	// Values is what a PET series' values are shown as from the start.
+	Values volume.ValueMode
+}
+
+func LoadSession(path string) (Session, error) {
+	var s Session
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+func (s Session) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// capture records the current state of the viewer.
+func (g *GuiState) capture(v volume.Volume, layout *Layout, input string) Session {
+	g.finishMeasure()
+	s := Session{
+		Input:        input,
+		Slice:        [3]float32{g.Slice.X, g.Slice.Y, g.Slice.Z},
+		Debug:        g.Debug,
+		Values:       g.valuesName(),
+		WindowCenter: v.DcmData.Window,
+		WindowWidth:  v.DcmData.Level,
+		LUT:          lutName(v.DcmData.LUT),
+		SplitX:       layout.SplitX,
+		SplitY:       layout.SplitY,
+		Views:        map[string]ViewState{},
+		Measurements: g.Measurements,
+	}
+	g.Oblique.ToArray(s.Oblique[:], 0)
+	for _, vp := range g.Views() {
+		zoom, center := vp.View()
+		s.Views[vp.Name] = ViewState{zoom, [2]float32{center.X, center.Y}}
+	}
+	return s
+}
+
+// restore brings the viewer back to session s, whose state is not undone,
+// so the history starts afresh. The caller lays the panes out again
+// afterwards, as the splits may have changed.
+func (g *GuiState) restore(s Session, v *volume.Volume, layout *Layout) {
+	size := dims(*v)
+	for i := 0; i < 3; i++ {
+		g.Slice.SetComponent(i, clampSlice(s.Slice[i], size[i]))
+	}
+	g.syncSliders(*v)
+	g.Debug = s.Debug
+	if g.DebugBox != nil {
+		g.DebugBox.SetValue(s.Debug)
+	}
+	if s.Values != "" {
+		mode, err := volume.ParseValueMode(s.Values)
+		if err == nil {
+			err = g.setValues(v, mode)
+		}
+		if err != nil {
+			log.Println("session values:", err)
+		}
+	}
+	if s.WindowWidth > 0 && (s.WindowCenter != v.DcmData.Window || s.WindowWidth != v.DcmData.Level) {
+		v.SetWindow(s.WindowCenter, s.WindowWidth)
+		g.cut = nil
+	}
+	lut := g.lutNamed(s.LUT)
+	if lut == nil && s.LUT != "" {
+		// Only the name is saved, so a table loaded from a file is found
+		// only when passed again with -lut.
+		log.Printf("session lookup table %q is not in the menu, showing gray", s.LUT)
+	}
+	if lut != v.DcmData.LUT {
+		g.setLUT(v, lut)
+		g.selectLUT(lut)
+	}
+	if s.Oblique != [16]float32{} {
+		g.Oblique.FromArray(s.Oblique[:], 0)
+		updateFree(g, *v)
+	}
+	if s.SplitX > 0 && s.SplitY > 0 {
+		layout.SplitX, layout.SplitY = clampSplit(s.SplitX), clampSplit(s.SplitY)
+	}
+	for _, vp := range g.Views() {
+		if view, ok := s.Views[vp.Name]; ok {
+			vp.SetView(view.Zoom, *math32.NewVector2(view.Center[0], view.Center[1]))
+		}
+	}
+	g.cancelMeasure()
+	g.Measurements = s.Measurements
+	g.History = History{}
+	g.Dirty = true
+}
+
+// placeSessionButtons adds buttons saving the session captured by capture to
+// path and restoring it from there.
+func placeSessionButtons(scene *gui.Panel, capture func() Session, restore func(Session), path string) {
+	save := gui.NewButton("Save session")
+	save.SetPosition(10, 270)
+	save.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		if err := capture().Save(path); err != nil {
+			log.Println("saving session:", err)
+			return
+		}
+		log.Println("session saved to", path)
+	})
+	scene.Add(save)
+	load := gui.NewButton("Load session")
+	load.SetPosition(14+save.Width(), 270)
+	load.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		s, err := LoadSession(path)
+		if err != nil {
+			log.Println("loading session:", err)
+			return
+		}
+		restore(s)
+	})
+	scene.Add(load)
+}
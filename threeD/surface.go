@@ -0,0 +1,174 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+)
+
+// SurfaceRequest says how to extract a surface: at Threshold in the
+// volume's values, or around Label in Mask when there is one, then smoothed
+// Smooth times and decimated into cells of Decimate mm when above zero. The
+// Export button writes to Dir in Format, stl, obj or ply.
+type SurfaceRequest struct {
+	Threshold float32
+	Mask      *volume.Volume
+	Label     float32
+	Smooth    int
+	Decimate  float32
+	Dir       string
+	Format    string
+}
+
+// Extract builds the surface req asks for from v.
+func (req SurfaceRequest) Extract(v volume.Volume) volume.Mesh {
+	var mesh volume.Mesh
+	if req.Mask != nil {
+		mesh = volume.LabelSurface(*req.Mask, req.Label)
+	} else {
+		mesh = volume.Isosurface(v, req.Threshold)
+	}
+	mesh.Smooth(req.Smooth)
+	return mesh.Decimate(req.Decimate)
+}
+
+// surfaceView shows the last surface extracted from v in the 3D scene, with
+// what the clip removes left out. Extraction runs in the background from a
+// snapshot of the volume.
+type surfaceView struct {
+	node    *core.Node
+	v       *volume.Volume
+	mesh    volume.Mesh
+	clip    volume.Clip
+	clipped volume.Mesh
+	shown   *graphic.Mesh
+	results chan volume.Mesh
+	busy    bool
+}
+
+func newSurfaceView(v *volume.Volume) *surfaceView {
+	return &surfaceView{node: core.NewNode(), v: v, results: make(chan volume.Mesh, 1)}
+}
+
+// Update shows the surface extracted since the last frame, if any.
+func (s *surfaceView) Update() {
+	select {
+	case mesh := <-s.results:
+		s.busy = false
+		s.set(mesh)
+	default:
+	}
+}
+
+// SetClip shows what clip leaves of the surface.
+func (s *surfaceView) SetClip(clip volume.Clip) {
+	s.clip = clip
+	s.set(s.mesh)
+}
+
+func (s *surfaceView) set(mesh volume.Mesh) {
+	s.mesh = mesh
+	mesh = mesh.Clip(*s.v, s.clip)
+	s.clipped = mesh
+	if s.shown != nil {
+		s.node.Remove(s.shown)
+		s.shown.Dispose()
+		s.shown = nil
+	}
+	if len(mesh.Indices) == 0 {
+		return
+	}
+	positions := math32.NewArrayF32(0, 3*len(mesh.Vertices))
+	normals := math32.NewArrayF32(0, 3*len(mesh.Vertices))
+	for i, n := range mesh.Normals() {
+		p := mesh.Vertices[i]
+		positions.Append(p.X, p.Y, p.Z)
+		normals.Append(n.X, n.Y, n.Z)
+	}
+	geom := geometry.NewGeometry()
+	geom.AddVBO(gls.NewVBO(positions).AddAttrib(gls.VertexPosition))
+	geom.AddVBO(gls.NewVBO(normals).AddAttrib(gls.VertexNormal))
+	geom.SetIndices(math32.ArrayU32(mesh.Indices))
+	mat := material.NewStandard(&math32.Color{0.95, 0.9, 0.8})
+	mat.SetSide(material.SideDouble)
+	s.shown = graphic.NewMesh(geom, mat)
+	s.node.Add(s.shown)
+}
+
+// placeSurfaceRow adds the threshold field and the buttons extracting the
+// surface of v and exporting it as shown. An empty threshold hides the
+// surface.
+func placeSurfaceRow(scene *gui.Panel, s *surfaceView, v *volume.Volume, req SurfaceRequest) {
+	label := gui.NewLabel("Surface at")
+	label.SetPosition(10, 334)
+	scene.Add(label)
+	threshold := gui.NewEdit(60, "HU")
+	threshold.SetPosition(80, 330)
+	if req.Mask == nil {
+		threshold.SetText(strconv.FormatFloat(float64(req.Threshold), 'g', -1, 32))
+	} else {
+		threshold.SetText("mask")
+	}
+	scene.Add(threshold)
+
+	extract := gui.NewButton("Surface")
+	extract.SetPosition(146, 326)
+	extract.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		if s.busy {
+			return
+		}
+		r := req
+		text := strings.TrimSpace(threshold.Text())
+		if text == "" {
+			s.set(volume.Mesh{})
+			return
+		}
+		if text != "mask" {
+			t, err := strconv.ParseFloat(text, 32)
+			if err != nil {
+				log.Println("surface threshold:", err)
+				return
+			}
+			r.Threshold, r.Mask = float32(t), nil
+		} else if r.Mask == nil {
+			log.Println("surface: no mask was given")
+			return
+		}
+		snapshot := *v
+		s.busy = true
+		go func() {
+			mesh := r.Extract(snapshot)
+			log.Printf("surface of %d triangles, %.0f mm³", len(mesh.Indices)/3, mesh.Volume())
+			s.results <- mesh
+		}()
+	})
+	scene.Add(extract)
+
+	export := gui.NewButton("Export")
+	export.SetPosition(extract.Position().X+extract.Width()+4, 326)
+	export.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		if len(s.clipped.Indices) == 0 {
+			log.Println("surface: nothing to export")
+			return
+		}
+		path := filepath.Join(req.Dir, fmt.Sprintf("surface-%s.%s", time.Now().Format("20060102-150405"), req.Format))
+		if err := volume.WriteMesh(path, s.clipped); err != nil {
+			log.Println("surface:", err)
+			return
+		}
+		log.Println("surface written to", path)
+	})
+	scene.Add(export)
+}
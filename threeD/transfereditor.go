@@ -0,0 +1,315 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/texture"
+	"github.com/g3n/engine/window"
+)
+
+const (
+	editorWidth  = 400
+	plotHeight   = 110
+	stripHeight  = 12
+	pointRadius  = 3
+	grabDistance = 6
+)
+
+// transferEditor edits a transfer function over the volume's histogram:
+// opacity runs up the plot and value across it, with the colours in a strip
+// underneath. Clicking adds a point, dragging moves one, right-clicking
+// removes it, and the sliders colour the selected point.
+type transferEditor struct {
+	*gui.Panel
+	tf       volume.TransferFunction
+	hist     []int
+	min      float32
+	max      float32
+	plot     *gui.Image
+	tex      *texture.Texture2D
+	name     *gui.Label
+	rgb      [3]*gui.Slider
+	selected int
+	dragging bool
+	syncing  bool
+	onChange func(volume.TransferFunction)
+}
+
+// newTransferEditor builds an editor over v's values calling onChange with a
+// copy of the function on every edit. Presets come from TransferPresets,
+// plus "Window" to follow the slices' window, reported as a nil function.
+func newTransferEditor(v volume.Volume, tf volume.TransferFunction, path string, onChange func(*volume.TransferFunction)) *transferEditor {
+	e := &transferEditor{Panel: gui.NewPanel(editorWidth, 210), tf: tf.Clone(), selected: -1}
+	e.SetColor4(&math32.Color4{0.85, 0.85, 0.85, 0.9})
+	e.hist, e.min, e.max = v.Histogram(editorWidth)
+	e.onChange = func(tf volume.TransferFunction) { onChange(&tf) }
+
+	presets := gui.NewDropDown(120, gui.NewImageLabel("Presets"))
+	presets.SetPosition(4, 4)
+	presets.Add(gui.NewImageLabel("Window"))
+	for _, p := range volume.TransferPresets(v.DcmData.Window, v.DcmData.Level) {
+		presets.Add(gui.NewImageLabel(p.Name))
+	}
+	presets.Subscribe(gui.OnChange, func(name string, ev interface{}) {
+		pos := presets.SelectedPos()
+		if pos == 0 {
+			e.set(volume.WindowTransfer(v.DcmData.Window, v.DcmData.Level))
+			onChange(nil)
+			return
+		}
+		e.set(volume.TransferPresets(v.DcmData.Window, v.DcmData.Level)[pos-1])
+		e.changed()
+	})
+	e.Add(presets)
+
+	save := gui.NewButton("Save")
+	save.SetPosition(130, 4)
+	save.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		if err := e.tf.Save(path); err != nil {
+			log.Println("saving transfer function:", err)
+			return
+		}
+		log.Println("transfer function saved to", path)
+	})
+	e.Add(save)
+	load := gui.NewButton("Load")
+	load.SetPosition(134+save.Width(), 4)
+	load.Subscribe(gui.OnClick, func(name string, ev interface{}) {
+		tf, err := volume.LoadTransferFunction(path)
+		if err != nil {
+			log.Println("loading transfer function:", err)
+			return
+		}
+		e.set(tf)
+		e.changed()
+	})
+	e.Add(load)
+	e.name = gui.NewLabel("")
+	e.name.SetPosition(138+save.Width()+load.Width(), 8)
+	e.Add(e.name)
+
+	e.tex = texture.NewTexture2DFromRGBA(e.draw())
+	e.plot = gui.NewImageFromTex(e.tex)
+	e.plot.SetPosition(0, 34)
+	e.plot.Subscribe(gui.OnMouseDown, e.onMouse)
+	e.plot.Subscribe(gui.OnMouseUp, e.onMouse)
+	e.plot.Subscribe(gui.OnCursor, e.onCursor)
+	e.Add(e.plot)
+
+	for i, name := range []string{"R", "G", "B"} {
+		i := i
+		s := gui.NewHSlider(120, 20)
+		s.SetPosition(4+float32(i)*130, 34+plotHeight+stripHeight+8)
+		s.SetText(name)
+		s.Subscribe(gui.OnChange, func(evname string, ev interface{}) {
+			if e.selected < 0 || e.syncing {
+				return
+			}
+			c := &e.tf.Points[e.selected].Color
+			switch i {
+			case 0:
+				c.R = s.Value()
+			case 1:
+				c.G = s.Value()
+			case 2:
+				c.B = s.Value()
+			}
+			e.edited()
+		})
+		e.Add(s)
+		e.rgb[i] = s
+	}
+	hint := gui.NewLabel("click adds a point, drag moves it, right-click removes it")
+	hint.SetPosition(4, 34+plotHeight+stripHeight+34)
+	e.Add(hint)
+	e.redraw()
+	return e
+}
+
+// set shows tf without reporting it.
+func (e *transferEditor) set(tf volume.TransferFunction) {
+	e.tf = tf.Clone()
+	e.pick(-1)
+	e.redraw()
+}
+
+// edited reports an edit by hand, after which the function is no longer
+// the one named.
+func (e *transferEditor) edited() {
+	e.tf.Name = ""
+	e.changed()
+}
+
+func (e *transferEditor) changed() {
+	e.redraw()
+	e.onChange(e.tf.Clone())
+}
+
+func (e *transferEditor) pick(i int) {
+	e.selected = i
+	if i < 0 {
+		return
+	}
+	c := e.tf.Points[i].Color
+	e.syncing = true
+	for j, value := range []float32{c.R, c.G, c.B} {
+		e.rgb[j].SetValue(value)
+	}
+	e.syncing = false
+}
+
+// valueAt, xOf and yOf convert between plot pixels and values and
+// opacities.
+func (e *transferEditor) valueAt(x float32) float32 {
+	return e.min + x/float32(editorWidth-1)*(e.max-e.min)
+}
+
+func (e *transferEditor) xOf(value float32) float32 {
+	if e.max == e.min {
+		return 0
+	}
+	return (value - e.min) / (e.max - e.min) * float32(editorWidth-1)
+}
+
+func (e *transferEditor) yOf(opacity float32) float32 {
+	return (1 - opacity) * float32(plotHeight-1)
+}
+
+// local returns where window position xpos, ypos falls in the plot.
+func (e *transferEditor) local(xpos float32, ypos float32) (float32, float32) {
+	pos := e.plot.Pospix()
+	return xpos - pos.X, ypos - pos.Y
+}
+
+// nearest returns the point within grabbing distance of plot x, y, or -1.
+func (e *transferEditor) nearest(x float32, y float32) int {
+	best, bestDist := -1, float32(grabDistance)
+	for i, p := range e.tf.Points {
+		d := math32.Max(math32.Abs(e.xOf(p.Value)-x), math32.Abs(e.yOf(p.Opacity)-y))
+		if d <= bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+func (e *transferEditor) onMouse(evname string, ev interface{}) {
+	mev := ev.(*window.MouseEvent)
+	x, y := e.local(mev.Xpos, mev.Ypos)
+	switch {
+	case evname == gui.OnMouseUp:
+		e.dragging = false
+		gui.Manager().SetCursorFocus(nil)
+	case mev.Button == window.MouseButtonRight:
+		if i := e.nearest(x, y); i >= 0 && len(e.tf.Points) > 1 {
+			e.tf.Points = append(e.tf.Points[:i], e.tf.Points[i+1:]...)
+			e.pick(-1)
+			e.edited()
+		}
+	case mev.Button == window.MouseButtonLeft && y < plotHeight:
+		i := e.nearest(x, y)
+		if i < 0 {
+			value := e.valueAt(x)
+			c, _ := e.tf.At(value)
+			p := volume.TransferPoint{value, c, math32.Clamp(1-y/float32(plotHeight-1), 0, 1)}
+			i = len(e.tf.Points)
+			for j, q := range e.tf.Points {
+				if q.Value > value {
+					i = j
+					break
+				}
+			}
+			e.tf.Points = append(e.tf.Points[:i], append([]volume.TransferPoint{p}, e.tf.Points[i:]...)...)
+			e.edited()
+		}
+		e.pick(i)
+		e.dragging = true
+		gui.Manager().SetCursorFocus(e.plot)
+		e.redraw()
+	}
+}
+
+func (e *transferEditor) onCursor(evname string, ev interface{}) {
+	if !e.dragging || e.selected < 0 {
+		return
+	}
+	cev := ev.(*window.CursorEvent)
+	x, y := e.local(cev.Xpos, cev.Ypos)
+	p := &e.tf.Points[e.selected]
+	// A point stays between its neighbours so the points keep their order.
+	lo, hi := e.min, e.max
+	if e.selected > 0 {
+		lo = e.tf.Points[e.selected-1].Value
+	}
+	if e.selected < len(e.tf.Points)-1 {
+		hi = e.tf.Points[e.selected+1].Value
+	}
+	p.Value = math32.Clamp(e.valueAt(x), lo, hi)
+	p.Opacity = math32.Clamp(1-y/float32(plotHeight-1), 0, 1)
+	e.edited()
+}
+
+// redraw updates the plot and the label naming the function or the point
+// selected.
+func (e *transferEditor) redraw() {
+	if e.tex == nil {
+		return
+	}
+	e.tex.SetFromRGBA(e.draw())
+	switch {
+	case e.selected >= 0:
+		p := e.tf.Points[e.selected]
+		e.name.SetText(fmt.Sprintf("%.0f  opacity %.2f", p.Value, p.Opacity))
+	default:
+		e.name.SetText(e.tf.Name)
+	}
+}
+
+// draw paints the histogram on a log scale, the opacity curve and points
+// over it, and the colour strip below.
+func (e *transferEditor) draw() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, editorWidth, plotHeight+stripHeight))
+	peak := 0.0
+	for _, n := range e.hist {
+		peak = math.Max(peak, math.Log1p(float64(n)))
+	}
+	fill := func(x0, y0, x1, y1 int, c color.RGBA) {
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				if x >= 0 && y >= 0 && x < editorWidth && y < plotHeight+stripHeight {
+					img.SetRGBA(x, y, c)
+				}
+			}
+		}
+	}
+	fill(0, 0, editorWidth, plotHeight, color.RGBA{40, 40, 40, 255})
+	for x, n := range e.hist {
+		if peak > 0 {
+			bar := int(math.Log1p(float64(n)) / peak * float64(plotHeight))
+			fill(x, plotHeight-bar, x+1, plotHeight, color.RGBA{90, 90, 90, 255})
+		}
+	}
+	toByte := func(f float32) uint8 { return uint8(math32.Clamp(f, 0, 1) * 255) }
+	for x := 0; x < editorWidth; x++ {
+		c, opacity := e.tf.At(e.valueAt(float32(x)))
+		fill(x, plotHeight, x+1, plotHeight+stripHeight, color.RGBA{toByte(c.R), toByte(c.G), toByte(c.B), 255})
+		y := int(e.yOf(opacity))
+		fill(x, y, x+1, y+1, color.RGBA{255, 255, 255, 255})
+	}
+	for i, p := range e.tf.Points {
+		x, y := int(e.xOf(p.Value)), int(e.yOf(p.Opacity))
+		c := color.RGBA{255, 255, 255, 255}
+		if i == e.selected {
+			c = color.RGBA{255, 220, 0, 255}
+		}
+		fill(x-pointRadius, y-pointRadius, x+pointRadius+1, y+pointRadius+1, c)
+	}
+	return img
+}
@@ -0,0 +1,94 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"fmt"
+	"log"
+
+	"github.com/g3n/engine/gui"
+)
+
+// valueModes is what a PET series' values are shown as, with the values as
+// loaded that every mode is computed from.
+type valueModes struct {
+	menu    *gui.DropDown
+	params  volume.SUVParams
+	raw     volume.Volume
+	current volume.ValueMode
+}
+
+// placeValueMenu adds, for a PET series whose headers allow it, the menu
+// showing v's values as activity or as one of the SUVs, starting with
+// initial. The images, the readout, the measurements and the volume rendering
+// all follow. Other series get no menu. Call it before anything changes v.
+func placeValueMenu(scene *gui.Panel, g *GuiState, v *volume.Volume, initial volume.ValueMode) {
+	p, err := v.SUV()
+	if err != nil {
+		if initial != volume.RawValues {
+			log.Println("values:", err)
+		}
+		return
+	}
+	modes := &valueModes{menu: gui.NewDropDown(100, gui.NewImageLabel(volume.RawValues.String())), params: p, raw: *v}
+	modes.menu.SetPosition(300, 330)
+	for _, mode := range []volume.ValueMode{volume.RawValues, volume.SUVbw, volume.SUVlbm, volume.SUVbsa} {
+		modes.menu.Add(gui.NewImageLabel(mode.String()))
+	}
+	modes.menu.Subscribe(gui.OnChange, func(name string, ev interface{}) {
+		if err := g.setValues(v, volume.ValueMode(modes.menu.SelectedPos())); err != nil {
+			log.Println("values:", err)
+			modes.menu.SelectPos(int(modes.current))
+		}
+	})
+	scene.Add(modes.menu)
+	g.values = modes
+	if err := g.setValues(v, initial); err != nil {
+		log.Println("values:", err)
+	}
+}
+
+// setValues shows v's values in mode, keeping the window where it was in
+// the mode shown before. The history starts afresh, as the windows it
+// recorded are in the other mode's units.
+func (g *GuiState) setValues(v *volume.Volume, mode volume.ValueMode) error {
+	m := g.values
+	if m == nil {
+		if mode == volume.RawValues {
+			return nil
+		}
+		return fmt.Errorf("%s needs a PET series with its dose in the headers", mode)
+	}
+	if mode == m.current {
+		return nil
+	}
+	shown, err := m.params.Factor(m.current, len(v.Values)/2)
+	if err != nil {
+		return err
+	}
+	// InMode scales the window it is given, so hand it the window in Bq/ml.
+	base := m.raw
+	base.DcmData.Window, base.DcmData.Level = v.DcmData.Window/shown, v.DcmData.Level/shown
+	converted, err := base.InMode(m.params, mode)
+	if err != nil {
+		return err
+	}
+	converted.DcmData.LUT = v.DcmData.LUT
+	*v = converted
+	m.current = mode
+	g.History = History{}
+	if m.menu.SelectedPos() != int(mode) {
+		m.menu.SelectPos(int(mode))
+	}
+	g.recut(*v)
+	g.DVR.Invalidate()
+	return nil
+}
+
+// valuesName names the mode shown for the session, empty for a series
+// without modes.
+func (g *GuiState) valuesName() string {
+	if g.values == nil {
+		return ""
+	}
+	return g.values.current.String()
+}
@@ -0,0 +1,393 @@
+package threeD
+
+import (
+	volume "awesomeProject/dicom"
+	"fmt"
+	"image"
+
+	"github.com/g3n/engine/camera"
+	"github.com/g3n/engine/core"
+	"github.com/g3n/engine/geometry"
+	"github.com/g3n/engine/gls"
+	"github.com/g3n/engine/graphic"
+	"github.com/g3n/engine/gui"
+	"github.com/g3n/engine/light"
+	"github.com/g3n/engine/material"
+	"github.com/g3n/engine/math32"
+	"github.com/g3n/engine/renderer"
+	"github.com/g3n/engine/texture"
+)
+
+// Viewport2D shows one SliceFrame flat, in millimetres, through an
+// orthographic camera looking down on the image.
+type Viewport2D struct {
+	Name   string
+	Axis   int
+	Color  *math32.Color
+	Scene  *core.Node
+	Cam    *camera.Camera
+	Pane   Pane
+	Frame  volume.SliceFrame
+	title  *gui.Label
+	left   *gui.Label
+	right  *gui.Label
+	top    *gui.Label
+	bottom *gui.Label
+	image  *graphic.Mesh
+	cross  *core.Node
+
+	// VoxelSize is the in-plane voxel spacing in mm, used for 1:1 zoom and
+	// to cap the detail resolution.
+	VoxelSize   float32
+	zoom        float32
+	center      math32.Vector2
+	placed      bool
+	detail      *graphic.Mesh
+	detailDirty bool
+	fitBtn      *gui.Button
+	oneBtn      *gui.Button
+
+	bar     *gui.Image
+	barTex  *texture.Texture2D
+	barLUT  *volume.LUT
+	barHigh *gui.Label
+	barLow  *gui.Label
+
+	// Overlay, when set, draws over every reformat shown, such as a fused
+	// volume.
+	Overlay func(volume.SliceFrame)
+
+	root        *core.Node
+	marks       *core.Node
+	markLabels  []*gui.Label
+	markAnchors []math32.Vector2
+}
+
+// NewViewport2D creates a viewport for the plane perpendicular to voxel axis
+// (0 for columns, 1 for rows, 2 for slices).
+func NewViewport2D(name string, axis int, root *core.Node, c *math32.Color) *Viewport2D {
+	vp := &Viewport2D{
+		Name:  name,
+		Axis:  axis,
+		Color: c,
+		Scene: core.NewNode(),
+		Cam:   camera.NewOrthographic(1, 0.1, 100, 1, camera.Vertical),
+		cross: core.NewNode(),
+		root:  root,
+		marks: core.NewNode(),
+	}
+	vp.Cam.SetPosition(0, 0, 10)
+	vp.Scene.Add(vp.Cam)
+	vp.Scene.Add(vp.cross)
+	vp.Scene.Add(vp.marks)
+	vp.Scene.Add(light.NewAmbient(&math32.Color{1, 1, 1}, 1))
+
+	vp.title = gui.NewLabel(name)
+	vp.title.SetColor(c)
+	vp.left = gui.NewLabel("")
+	vp.right = gui.NewLabel("")
+	vp.top = gui.NewLabel("")
+	vp.bottom = gui.NewLabel("")
+	for _, l := range []*gui.Label{vp.title, vp.left, vp.right, vp.top, vp.bottom} {
+		l.SetColor(c)
+		root.Add(l)
+	}
+	vp.fitBtn = gui.NewButton("Fit")
+	vp.fitBtn.Subscribe(gui.OnClick, func(name string, ev interface{}) { vp.Fit() })
+	root.Add(vp.fitBtn)
+	vp.oneBtn = gui.NewButton("1:1")
+	vp.oneBtn.Subscribe(gui.OnClick, func(name string, ev interface{}) { vp.OneToOne() })
+	root.Add(vp.oneBtn)
+	vp.barHigh = gui.NewLabel("")
+	vp.barLow = gui.NewLabel("")
+	for _, l := range []*gui.Label{vp.barHigh, vp.barLow} {
+		l.SetColor(c)
+		l.SetVisible(false)
+		root.Add(l)
+	}
+	vp.zoom = 1
+	return vp
+}
+
+const (
+	barWidth  = 12
+	barHeight = 128
+)
+
+// SetColorBar shows the colours of data's lookup table against the values
+// its window maps to them, or nothing for gray.
+func (vp *Viewport2D) SetColorBar(data volume.DcmData) {
+	shown := data.LUT != nil
+	vp.barHigh.SetVisible(shown)
+	vp.barLow.SetVisible(shown)
+	if vp.bar != nil {
+		vp.bar.SetVisible(shown)
+	}
+	if !shown {
+		return
+	}
+	if data.LUT != vp.barLUT {
+		vp.barLUT = data.LUT
+		img := data.LUT.Bar(barWidth, barHeight)
+		if vp.bar == nil {
+			vp.barTex = texture.NewTexture2DFromRGBA(img)
+			vp.bar = gui.NewImageFromTex(vp.barTex)
+			vp.root.Add(vp.bar)
+		} else {
+			vp.barTex.SetFromRGBA(img)
+		}
+	}
+	vp.barHigh.SetText(fmt.Sprintf("%.0f", data.Window+data.Level/2))
+	vp.barLow.SetText(fmt.Sprintf("%.0f", data.Window-data.Level/2))
+	vp.placeLabels()
+}
+
+func texturedPlane(img *image.RGBA, w float32, h float32) *graphic.Mesh {
+	mat := material.NewStandard(&math32.Color{1, 1, 1})
+	mat.AddTexture(texture.NewTexture2DFromRGBA(img))
+	return graphic.NewMesh(geometry.NewPlane(w, h), mat)
+}
+
+func (vp *Viewport2D) removeMesh(m **graphic.Mesh) {
+	if *m != nil {
+		vp.Scene.Remove(*m)
+		(*m).Dispose()
+		*m = nil
+	}
+}
+
+// imageSize returns the image extent in mm, from the pixel grid so the
+// aspect follows ImagePixelSize.
+func (vp *Viewport2D) imageSize() (float32, float32) {
+	return vp.Frame.ImageSize.X * vp.Frame.ImagePixelSize.X, vp.Frame.ImageSize.Y * vp.Frame.ImagePixelSize.Y
+}
+
+// SetFrame replaces the displayed image with frame's reformat.
+func (vp *Viewport2D) SetFrame(frame volume.SliceFrame) {
+	first := vp.Frame.ImageSize == nil
+	vp.Frame = frame
+	vp.removeMesh(&vp.image)
+	vp.removeMesh(&vp.detail)
+	vp.detailDirty = true
+	if (*frame.Mpr).Bounds().Empty() {
+		return
+	}
+	w, h := vp.imageSize()
+	vp.image = texturedPlane(*frame.Mpr, w, h)
+	vp.Scene.Add(vp.image)
+
+	labels := frame.Labels()
+	vp.left.SetText(labels.Left)
+	vp.right.SetText(labels.Right)
+	vp.top.SetText(labels.Top)
+	vp.bottom.SetText(labels.Bottom)
+	if first && !vp.placed {
+		vp.Fit()
+	} else {
+		vp.apply()
+	}
+	vp.placeLabels()
+}
+
+// SetCrosshair draws where the planes of others cross this image, each in
+// its viewport's colour.
+func (vp *Viewport2D) SetCrosshair(others ...*Viewport2D) {
+	vp.cross.RemoveAll(true)
+	if vp.Frame.ImageSizeInMm == nil {
+		return
+	}
+	w, h := vp.imageSize()
+	for _, other := range others {
+		if other.Frame.RotatedFrame.Plane == nil {
+			continue
+		}
+		a, b, ok := vp.Frame.CrossLine(other.Frame)
+		if !ok {
+			continue
+		}
+		geom := geometry.NewGeometry()
+		positions := math32.NewArrayF32(0, 0)
+		positions.Append(a.X-w/2, h/2-a.Y, 1, b.X-w/2, h/2-b.Y, 1)
+		geom.AddVBO(gls.NewVBO(positions).AddAttrib(gls.VertexPosition))
+		vp.cross.Add(graphic.NewLines(geom, material.NewStandard(other.Color)))
+	}
+}
+
+// SetPane moves the viewport to pane, keeping its zoom and pan.
+func (vp *Viewport2D) SetPane(p Pane) {
+	vp.Pane = p
+	vp.Cam.SetAspect(p.Aspect())
+	vp.apply()
+	vp.placeLabels()
+}
+
+// fitSize is the camera size, in mm, that shows the whole image.
+func (vp *Viewport2D) fitSize() float32 {
+	if vp.Frame.ImageSize == nil {
+		return 1
+	}
+	w, h := vp.imageSize()
+	return math32.Max(h, w/vp.Pane.Aspect()) * 1.05
+}
+
+func (vp *Viewport2D) apply() {
+	if size := vp.fitSize() / vp.zoom; size > 0 {
+		vp.Cam.SetSize(size)
+	}
+	vp.Cam.SetPosition(vp.center.X, vp.center.Y, 10)
+	vp.detailDirty = true
+	vp.placeMarks()
+}
+
+// Fit shows the whole image, centred.
+func (vp *Viewport2D) Fit() {
+	vp.zoom = 1
+	vp.center.Set(0, 0)
+	vp.apply()
+}
+
+// View returns the zoom, 1 when fitted, and the pan in mm from the image
+// centre.
+func (vp *Viewport2D) View() (float32, math32.Vector2) {
+	return vp.zoom, vp.center
+}
+
+// SetView restores a zoom and pan returned by View.
+func (vp *Viewport2D) SetView(zoom float32, center math32.Vector2) {
+	if zoom <= 0 {
+		return
+	}
+	vp.zoom = zoom
+	vp.center = center
+	vp.placed = true
+	vp.apply()
+}
+
+// OneToOne zooms so that one screen pixel covers one voxel.
+func (vp *Viewport2D) OneToOne() {
+	if vp.VoxelSize <= 0 || vp.Pane.Height <= 0 {
+		return
+	}
+	vp.zoom = vp.fitSize() / (float32(vp.Pane.Height) * vp.VoxelSize)
+	vp.apply()
+}
+
+// ZoomAt scales the view by factor keeping the point under window x, y fixed.
+func (vp *Viewport2D) ZoomAt(x float32, y float32, factor float32) {
+	nx, ny := vp.Pane.NDC(x, y)
+	half := vp.Cam.Size() / 2
+	wx := vp.center.X + nx*half*vp.Pane.Aspect()
+	wy := vp.center.Y + ny*half
+	vp.zoom = math32.Clamp(vp.zoom*factor, 0.1, 100)
+	half = vp.fitSize() / vp.zoom / 2
+	vp.center.Set(wx-nx*half*vp.Pane.Aspect(), wy-ny*half)
+	vp.apply()
+}
+
+// Pan moves the image by dx, dy window pixels.
+func (vp *Viewport2D) Pan(dx float32, dy float32) {
+	if vp.Pane.Height <= 0 {
+		return
+	}
+	mmPerPixel := vp.Cam.Size() / float32(vp.Pane.Height)
+	vp.center.X -= dx * mmPerPixel
+	vp.center.Y += dy * mmPerPixel
+	vp.apply()
+}
+
+// UpdateDetail re-cuts the visible part of the image at screen resolution
+// when zoomed in past the base reformat, so zooming shows voxel detail.
+func (vp *Viewport2D) UpdateDetail(v volume.Volume) {
+	if !vp.detailDirty || vp.Frame.ImageSize == nil || vp.Pane.Height <= 0 {
+		return
+	}
+	vp.detailDirty = false
+	vp.removeMesh(&vp.detail)
+
+	pixelSize := math32.Max(vp.Cam.Size()/float32(vp.Pane.Height), vp.VoxelSize/2)
+	if pixelSize >= vp.Frame.ImagePixelSize.X {
+		return
+	}
+	w, h := vp.imageSize()
+	halfW := vp.Cam.Size() * vp.Pane.Aspect() / 2
+	halfH := vp.Cam.Size() / 2
+	u0 := math32.Max(0, vp.center.X-halfW+w/2)
+	u1 := math32.Min(w, vp.center.X+halfW+w/2)
+	v0 := math32.Max(0, h/2-vp.center.Y-halfH)
+	v1 := math32.Min(h, h/2-vp.center.Y+halfH)
+	if u1 <= u0 || v1 <= v0 {
+		return
+	}
+	min := math32.NewVector2(vp.Frame.Box2f.Min.X+u0, vp.Frame.Box2f.Min.Y+v0)
+	max := math32.NewVector2(vp.Frame.Box2f.Min.X+u1, vp.Frame.Box2f.Min.Y+v1)
+	region := vp.Frame.Region(volume.AABB2f([]*math32.Vector2{min, max}), pixelSize)
+	region.Cut(v)
+	if vp.Overlay != nil {
+		vp.Overlay(region)
+	}
+
+	mw := region.ImageSize.X * pixelSize
+	mh := region.ImageSize.Y * pixelSize
+	vp.detail = texturedPlane(*region.Mpr, mw, mh)
+	vp.detail.SetPosition(u0-w/2+mw/2, h/2-v0-mh/2, 0.5)
+	vp.Scene.Add(vp.detail)
+}
+
+func (vp *Viewport2D) placeLabels() {
+	p := vp.Pane
+	cx := float32(p.X) + float32(p.Width)/2
+	cy := float32(p.Y) + float32(p.Height)/2
+	vp.title.SetPosition(float32(p.X)+6, float32(p.Y)+4)
+	vp.top.SetPosition(cx-vp.top.Width()/2, float32(p.Y)+4)
+	vp.bottom.SetPosition(cx-vp.bottom.Width()/2, float32(p.Y+p.Height)-vp.bottom.Height()-4)
+	vp.left.SetPosition(float32(p.X)+6, cy-vp.left.Height()/2)
+	vp.right.SetPosition(float32(p.X+p.Width)-vp.right.Width()-6, cy-vp.right.Height()/2)
+	vp.fitBtn.SetPosition(float32(p.X+p.Width)-vp.fitBtn.Width()-6, float32(p.Y)+4)
+	vp.oneBtn.SetPosition(float32(p.X+p.Width)-vp.fitBtn.Width()-vp.oneBtn.Width()-12, float32(p.Y)+4)
+	if vp.bar != nil {
+		// Under the zoom buttons, the values to its left.
+		x := float32(p.X+p.Width) - barWidth - 6
+		y := float32(p.Y) + vp.fitBtn.Height() + 12
+		vp.bar.SetPosition(x, y)
+		vp.barHigh.SetPosition(x-vp.barHigh.Width()-4, y)
+		vp.barLow.SetPosition(x-vp.barLow.Width()-4, y+barHeight-vp.barLow.Height())
+	}
+}
+
+// PlanePoint converts window point x, y into mm on the image, measured from
+// its top left corner.
+func (vp *Viewport2D) PlanePoint(x float32, y float32) (float32, float32) {
+	nx, ny := vp.Pane.NDC(x, y)
+	size := vp.Cam.Size()
+	pos := vp.Cam.Position()
+	wx := pos.X + nx*size*vp.Pane.Aspect()/2
+	wy := pos.Y + ny*size/2
+	w, h := vp.imageSize()
+	return wx + w/2, h/2 - wy
+}
+
+// ScreenAt converts x, y mm from the image's top left corner into a window
+// point, the inverse of PlanePoint.
+func (vp *Viewport2D) ScreenAt(x float32, y float32) (float32, float32) {
+	w, h := vp.imageSize()
+	size := vp.Cam.Size()
+	pos := vp.Cam.Position()
+	nx := (x - w/2 - pos.X) / (size * vp.Pane.Aspect() / 2)
+	ny := (h/2 - y - pos.Y) / (size / 2)
+	return float32(vp.Pane.X) + (nx+1)/2*float32(vp.Pane.Width),
+		float32(vp.Pane.Y) + (1-ny)/2*float32(vp.Pane.Height)
+}
+
+// PatientAt returns the patient coordinate under window point x, y.
+func (vp *Viewport2D) PatientAt(x float32, y float32) *math32.Vector3 {
+	u, v := vp.PlanePoint(x, y)
+	return vp.Frame.PlaneToPatient(vp.Frame.Box2f.Min.X+u, vp.Frame.Box2f.Min.Y+v)
+}
+
+func (vp *Viewport2D) Render(gs *gls.GLS, r *renderer.Renderer, windowHeight int) {
+	if vp.Pane.Width <= 0 || vp.Pane.Height <= 0 {
+		return
+	}
+	gs.Viewport(vp.Pane.Viewport(windowHeight))
+	r.Render(vp.Scene, vp.Cam)
+}